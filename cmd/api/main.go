@@ -8,6 +8,7 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strings"
 
 	"github.com/y-mitsuyoshi/kensho/kensho"
 )
@@ -25,8 +26,24 @@ func main() {
 	var err error
 
 	// The client now uses the default embedded configuration.
+	apiKey := os.Getenv("GEMINI_API_KEY")
 	modelName := os.Getenv("GEMINI_MODEL") // Read the model name from environment variable
-	kenshoClient, err = kensho.NewClient(ctx, os.Getenv("GEMINI_API_KEY"), modelName)
+	var opts []kensho.ClientOption
+	if fallback := os.Getenv("GEMINI_FALLBACK_MODELS"); fallback != "" {
+		// Comma-separated models tried in order when the result of the previous one is not good enough.
+		opts = append(opts, kensho.WithFallbackModels(strings.Split(fallback, ",")...))
+	}
+	if os.Getenv("LEGACY_VALIDATION") == "true" {
+		// Keep the response JSON of clients that only understand the validation string.
+		opts = append(opts, kensho.WithLegacyValidation())
+	}
+	if os.Getenv("MODEL_PROVIDER") == string(kensho.ProviderOpenAI) {
+		// Use an OpenAI-compatible chat completions API, e.g. a self-hosted vision model.
+		apiKey = os.Getenv("OPENAI_API_KEY")
+		modelName = os.Getenv("OPENAI_MODEL")
+		opts = append(opts, kensho.WithProvider(kensho.ProviderOpenAI), kensho.WithBaseURL(os.Getenv("OPENAI_BASE_URL")))
+	}
+	kenshoClient, err = kensho.NewClient(ctx, apiKey, modelName, opts...)
 	if err != nil {
 		log.Fatalf("Failed to create kensho client: %v", err)
 	}
@@ -35,6 +52,7 @@ func main() {
 
 	http.HandleFunc("/health", healthHandler)
 	http.HandleFunc("/api/v1/extract", extractHandler)
+	http.HandleFunc("/api/v1/usage", usageHandler)
 
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -49,8 +67,14 @@ func healthHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(Health{Status: "ok"})
 }
 
+// usageHandler returns the token usage and estimated cost per document type since startup.
+func usageHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(kenshoClient.Usage())
+}
+
 func extractHandler(w http.ResponseWriter, r *http.Request) {
-	docType, fileParts, masking, preprocess, err := kensho.ParseRequest(r)
+	req, err := kensho.ParseExtractRequest(r)
 	if err != nil {
 		switch {
 		case errors.Is(err, kensho.ErrRequestBodyTooLarge):
@@ -63,14 +87,21 @@ func extractHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	result, err := kenshoClient.Extract(r.Context(), fileParts, docType, masking, preprocess)
+	result, err := kenshoClient.ExtractDocument(r.Context(), *req)
 	if err != nil {
-		if errors.Is(err, kensho.ErrUnsupportedDocumentType) || errors.Is(err, kensho.ErrUnsupportedMimeType) {
+		switch {
+		case errors.Is(err, kensho.ErrUnsupportedDocumentType), errors.Is(err, kensho.ErrUnsupportedMimeType):
 			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
+		case errors.Is(err, kensho.ErrRateLimited):
+			http.Error(w, err.Error(), http.StatusTooManyRequests)
+		case errors.Is(err, kensho.ErrModelUnavailable):
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		case errors.Is(err, kensho.ErrSafetyBlocked):
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		default:
+			log.Printf("Error from kensho client: %v", err)
+			http.Error(w, fmt.Sprintf("Failed to extract data: %v", err), http.StatusInternalServerError)
 		}
-		log.Printf("Error from kensho client: %v", err)
-		http.Error(w, fmt.Sprintf("Failed to extract data: %v", err), http.StatusInternalServerError)
 		return
 	}
 
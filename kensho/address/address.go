@@ -0,0 +1,326 @@
+// Package address parses and normalizes Japanese addresses.
+package address
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// ErrUnknownPrefecture is returned when an address starts with a prefecture that does not
+// exist, such as 東京府.
+var ErrUnknownPrefecture = errors.New("unknown prefecture")
+
+// Address is a Japanese address split into its parts.
+type Address struct {
+	// PostalCode is the postal code (郵便番号) in the form 100-8977, if known.
+	PostalCode string
+	// Prefecture is the prefecture (都道府県), e.g. 東京都.
+	Prefecture string
+	// City is the municipality (市区町村), including the county (郡) of towns and villages
+	// and the city of the wards of designated cities, e.g. 横浜市中区.
+	City string
+	// Town is the town or district (町域), e.g. 霞が関.
+	Town string
+	// Block is the block and lot number (番地) in Arabic numerals separated by hyphens, with
+	// 丁目, 番 and 号 written as hyphens, e.g. 2-1-1.
+	Block string
+	// Building is the rest of the address, such as the name of the building and the room.
+	Building string
+}
+
+// String returns the normalized address, preceded by the postal code if known.
+func (a Address) String() string {
+	s := a.Prefecture + a.City + a.Town + a.Block
+	if a.Building != "" {
+		s += " " + a.Building
+	}
+	if a.PostalCode != "" {
+		s = "〒" + a.PostalCode + " " + s
+	}
+	return s
+}
+
+// Prefectures lists the prefectures of Japan.
+var Prefectures = []string{
+	"北海道", "青森県", "岩手県", "宮城県", "秋田県", "山形県", "福島県",
+	"茨城県", "栃木県", "群馬県", "埼玉県", "千葉県", "東京都", "神奈川県",
+	"新潟県", "富山県", "石川県", "福井県", "山梨県", "長野県", "岐阜県",
+	"静岡県", "愛知県", "三重県", "滋賀県", "京都府", "大阪府", "兵庫県",
+	"奈良県", "和歌山県", "鳥取県", "島根県", "岡山県", "広島県", "山口県",
+	"徳島県", "香川県", "愛媛県", "高知県", "福岡県", "佐賀県", "長崎県",
+	"熊本県", "大分県", "宮崎県", "鹿児島県", "沖縄県",
+}
+
+// Parse splits an address into its parts. Full-width characters are converted, and the
+// block number is normalized: 霞が関二丁目1番1号 becomes 霞が関 and 2-1-1. A leading postal
+// code is recognized. Addresses may leave out the prefecture.
+//
+// Without a postal code dataset, municipalities are recognized by the suffixes 市, 区, 町
+// and 村, which is wrong for a few names; use Dictionary.Parse for an exact split. When the
+// address starts with a prefecture that does not exist, the parts are returned along with
+// ErrUnknownPrefecture.
+func Parse(s string) (Address, error) {
+	return parse(s, nil)
+}
+
+// Normalize returns the normalized form of an address, as returned by Address.String.
+func Normalize(s string) string {
+	a, _ := Parse(s)
+	return a.String()
+}
+
+// parse splits an address, using the municipalities and towns of d if it is not nil.
+func parse(s string, d *Dictionary) (Address, error) {
+	var a Address
+	s = strings.Join(strings.Fields(norm.NFKC.String(s)), " ")
+	a.PostalCode, s = cutPostalCode(s)
+
+	var err error
+	a.Prefecture, s, err = cutPrefecture(s)
+	if d != nil && err == nil {
+		a.Prefecture, a.City, s = d.cutCity(a.Prefecture, a.PostalCode, s)
+	}
+	if a.City == "" {
+		a.City, s = cutCity(s)
+	}
+	if d != nil && err == nil {
+		a.Town, s = d.cutTown(a.Prefecture, a.City, s)
+	}
+	town, block, building := splitBlock(s)
+	a.Town += town
+	a.Block, a.Building = block, building
+	return a, err
+}
+
+// postalCodeRegex matches a postal code at the start of an address, e.g. 〒100-8977.
+var postalCodeRegex = regexp.MustCompile(`^〒? ?([0-9]{3})[-‐‑–—―−ー]?([0-9]{4})(?: |$)`)
+
+// cutPostalCode removes the postal code from the start of s.
+func cutPostalCode(s string) (string, string) {
+	m := postalCodeRegex.FindStringSubmatch(s)
+	if m == nil {
+		return "", s
+	}
+	return m[1] + "-" + m[2], strings.TrimSpace(s[len(m[0]):])
+}
+
+// prefectureLikeRegex matches a name ending like a prefecture, with no municipality suffix
+// before it.
+var prefectureLikeRegex = regexp.MustCompile(`^[^ 0-9市区町村郡都道府県]{2,3}[都道府県]`)
+
+// municipalitySuffixes lists the suffixes of municipalities and counties.
+const municipalitySuffixes = "市区町村郡"
+
+// cutPrefecture removes the prefecture from the start of s. A name ending like a
+// prefecture that is not one is removed and reported as ErrUnknownPrefecture, unless a
+// municipality suffix follows it, as in 太宰府市.
+func cutPrefecture(s string) (string, string, error) {
+	for _, p := range Prefectures {
+		if strings.HasPrefix(s, p) {
+			return p, strings.TrimSpace(s[len(p):]), nil
+		}
+	}
+	p := prefectureLikeRegex.FindString(s)
+	if p == "" {
+		return "", s, nil
+	}
+	if next, _ := utf8.DecodeRuneInString(s[len(p):]); strings.ContainsRune(municipalitySuffixes, next) {
+		return "", s, nil
+	}
+	return p, strings.TrimSpace(s[len(p):]), fmt.Errorf("%w: %s", ErrUnknownPrefecture, p)
+}
+
+// irregularCities lists municipalities whose names break the suffix rules of cutCity.
+var irregularCities = []string{
+	"四日市市", "廿日市市", "野々市市", "大和郡山市", "小郡市", "蒲郡市",
+	"余市郡余市町", "余市郡仁木町", "余市郡赤井川村", "高市郡高取町", "高市郡明日香村",
+	"佐波郡玉村町", "杵島郡大町町",
+}
+
+// cityPatterns recognize a municipality by its suffixes, tried in order: a town or village
+// in a county, a ward of a designated city, a city, and a ward of Tokyo, a town or a village.
+var cityPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`^[^ 市区]+?郡[^ ]+?[町村]`),
+	regexp.MustCompile(`^[^ 区]+?市[^ 市区]{1,4}区`),
+	regexp.MustCompile(`^[^ 区]+?市`),
+	regexp.MustCompile(`^[^ ]+?[区町村]`),
+}
+
+// cutCity removes the municipality from the start of s.
+func cutCity(s string) (string, string) {
+	for _, c := range irregularCities {
+		if strings.HasPrefix(s, c) {
+			return c, strings.TrimSpace(s[len(c):])
+		}
+	}
+	for _, p := range cityPatterns {
+		if c := p.FindString(s); c != "" {
+			return c, strings.TrimSpace(s[len(c):])
+		}
+	}
+	return "", s
+}
+
+// kanjiDigits maps the kanji numerals to their values.
+var kanjiDigits = map[rune]int{
+	'〇': 0, '零': 0, '一': 1, '二': 2, '三': 3, '四': 4,
+	'五': 5, '六': 6, '七': 7, '八': 8, '九': 9,
+}
+
+// kanjiUnits maps the kanji numerals for powers of ten to their values.
+var kanjiUnits = map[rune]int{'十': 10, '百': 100, '千': 1000}
+
+// isDigit reports whether r is an ASCII digit.
+func isDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
+
+// isKanjiNumeral reports whether r is a kanji numeral.
+func isKanjiNumeral(r rune) bool {
+	_, digit := kanjiDigits[r]
+	_, unit := kanjiUnits[r]
+	return digit || unit
+}
+
+// ParseKanjiNumeral returns the value of a number written in kanji, either with units
+// such as 二十三 or digit by digit such as 二〇三.
+func ParseKanjiNumeral(s string) (int, bool) {
+	if s == "" {
+		return 0, false
+	}
+	total, digit := 0, -1
+	for _, r := range s {
+		if v, ok := kanjiDigits[r]; ok {
+			if digit < 0 {
+				digit = 0
+			}
+			digit = digit*10 + v
+			continue
+		}
+		unit, ok := kanjiUnits[r]
+		if !ok {
+			return 0, false
+		}
+		if digit < 0 {
+			digit = 1
+		}
+		total += digit * unit
+		digit = -1
+	}
+	if digit >= 0 {
+		total += digit
+	}
+	return total, true
+}
+
+// numeral returns the end of the run of digits or of kanji numerals starting at i.
+func numeral(rs []rune, i int) int {
+	class := isDigit
+	if !isDigit(rs[i]) {
+		class = isKanjiNumeral
+	}
+	j := i
+	for j < len(rs) && class(rs[j]) {
+		j++
+	}
+	return j
+}
+
+// hyphens lists the characters written as hyphens between block numbers.
+const hyphens = "-‐‑–—―−ー"
+
+// marker returns the length of the block marker (丁目, 番地, 番, 号 or a hyphen) at the
+// start of rs, or 0 if there is none. 番 followed by 町 or 丁 is part of a town name such
+// as 六番町, and 号室 marks a room.
+func marker(rs []rune) int {
+	s := string(rs)
+	switch {
+	case strings.HasPrefix(s, "丁目"), strings.HasPrefix(s, "番地"):
+		return 2
+	case strings.HasPrefix(s, "番町"), strings.HasPrefix(s, "番丁"), strings.HasPrefix(s, "号室"):
+		return 0
+	case strings.HasPrefix(s, "番"), strings.HasPrefix(s, "号"):
+		return 1
+	case len(rs) > 0 && strings.ContainsRune(hyphens, rs[0]):
+		return 1
+	}
+	return 0
+}
+
+// blockStart reports whether the numeral ending at j starts the block number: a kanji
+// numeral must be followed by a marker, and digits by a marker, の, a space or the end.
+// Numbers followed by other characters, such as 北1条, are part of the town name.
+func blockStart(rs []rune, i, j int) bool {
+	if marker(rs[j:]) > 0 {
+		return true
+	}
+	return isDigit(rs[i]) && (j == len(rs) || rs[j] == ' ' || rs[j] == 'の')
+}
+
+// splitBlock splits the rest of an address after the municipality into the town, the
+// normalized block number and the building.
+func splitBlock(s string) (town, block, building string) {
+	rs := []rune(s)
+	start := -1
+	for i := 0; i < len(rs) && start < 0; i++ {
+		if !isDigit(rs[i]) && !isKanjiNumeral(rs[i]) {
+			continue
+		}
+		j := numeral(rs, i)
+		if blockStart(rs, i, j) {
+			start = i
+		}
+		i = j - 1
+	}
+	if start < 0 {
+		return strings.ReplaceAll(s, " ", ""), "", ""
+	}
+
+	var parts []string
+	k := start
+	for k < len(rs) && (isDigit(rs[k]) || isKanjiNumeral(rs[k])) {
+		j := numeral(rs, k)
+		if len(parts) > 0 && j < len(rs) && !blockStart(rs, k, j) {
+			break
+		}
+		n, ok := parseNumeral(string(rs[k:j]))
+		if !ok || strings.HasPrefix(string(rs[j:]), "号室") {
+			break
+		}
+		parts = append(parts, strconv.Itoa(n))
+		k = j
+
+		// A space continues the block only after a marker, as in 2丁目 1-1, and 号 ends it.
+		next, marked, last := k, false, false
+		for next < len(rs) && !last {
+			if l := marker(rs[next:]); l > 0 {
+				last = rs[next] == '号'
+				next += l
+				marked = true
+			} else if rs[next] == 'の' || rs[next] == ' ' && marked {
+				next++
+			} else {
+				break
+			}
+		}
+		k = next
+		if last {
+			break
+		}
+	}
+	return strings.ReplaceAll(string(rs[:start]), " ", ""), strings.Join(parts, "-"), strings.TrimSpace(string(rs[k:]))
+}
+
+// parseNumeral returns the value of a run of digits or of kanji numerals.
+func parseNumeral(s string) (int, bool) {
+	if n, err := strconv.Atoi(s); err == nil {
+		return n, true
+	}
+	return ParseKanjiNumeral(s)
+}
@@ -0,0 +1,206 @@
+package address
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding/japanese"
+)
+
+func TestParse(t *testing.T) {
+	t.Run("should split addresses into their parts", func(t *testing.T) {
+		testCases := []struct {
+			input    string
+			expected Address
+		}{
+			{"東京都千代田区霞が関2-1-1", Address{Prefecture: "東京都", City: "千代田区", Town: "霞が関", Block: "2-1-1"}},
+			{"〒100-8977 東京都千代田区霞が関一丁目１番１号", Address{PostalCode: "100-8977", Prefecture: "東京都", City: "千代田区", Town: "霞が関", Block: "1-1-1"}},
+			{"東京都新宿区西新宿２丁目８番１号 東京都庁第一本庁舎", Address{Prefecture: "東京都", City: "新宿区", Town: "西新宿", Block: "2-8-1", Building: "東京都庁第一本庁舎"}},
+			{"大阪府大阪市中央区大手前２丁目１－２２", Address{Prefecture: "大阪府", City: "大阪市中央区", Town: "大手前", Block: "2-1-22"}},
+			{"神奈川県横浜市中区日本大通１", Address{Prefecture: "神奈川県", City: "横浜市中区", Town: "日本大通", Block: "1"}},
+			{"北海道余市郡余市町朝日町26", Address{Prefecture: "北海道", City: "余市郡余市町", Town: "朝日町", Block: "26"}},
+			{"長野県北佐久郡軽井沢町大字軽井沢1323番地の28", Address{Prefecture: "長野県", City: "北佐久郡軽井沢町", Town: "大字軽井沢", Block: "1323-28"}},
+			{"三重県四日市市諏訪町1番5号", Address{Prefecture: "三重県", City: "四日市市", Town: "諏訪町", Block: "1-5"}},
+			{"東京都東村山市本町一丁目2番地3", Address{Prefecture: "東京都", City: "東村山市", Town: "本町", Block: "1-2-3"}},
+			{"東京都新宿区市谷加賀町二丁目十一番一号", Address{Prefecture: "東京都", City: "新宿区", Town: "市谷加賀町", Block: "2-11-1"}},
+			{"東京都千代田区六番町1-2-3 サンハイツ101", Address{Prefecture: "東京都", City: "千代田区", Town: "六番町", Block: "1-2-3", Building: "サンハイツ101"}},
+			{"東京都中央区八丁堀三丁目 1-1 101号室", Address{Prefecture: "東京都", City: "中央区", Town: "八丁堀", Block: "3-1-1", Building: "101号室"}},
+			{"北海道札幌市中央区北1条西2丁目", Address{Prefecture: "北海道", City: "札幌市中央区", Town: "北1条西", Block: "2"}},
+			{"千代田区 霞が関 ２ー１ー１", Address{City: "千代田区", Town: "霞が関", Block: "2-1-1"}},
+			{"太宰府市観世音寺4-6-1", Address{City: "太宰府市", Town: "観世音寺", Block: "4-6-1"}},
+			{"府中市宮西町2-24", Address{City: "府中市", Town: "宮西町", Block: "2-24"}},
+		}
+
+		for _, tc := range testCases {
+			t.Run(tc.input, func(t *testing.T) {
+				a, err := Parse(tc.input)
+				if err != nil {
+					t.Fatalf("expected no error, but got %v", err)
+				}
+				if a != tc.expected {
+					t.Errorf("expected %+v, but got %+v", tc.expected, a)
+				}
+			})
+		}
+	})
+
+	t.Run("should flag prefectures that do not exist", func(t *testing.T) {
+		a, err := Parse("東京府千代田区霞が関2-1-1")
+		if !errors.Is(err, ErrUnknownPrefecture) {
+			t.Fatalf("expected ErrUnknownPrefecture, but got %v", err)
+		}
+		if a.Prefecture != "東京府" || a.City != "千代田区" {
+			t.Errorf("expected the parts to be returned, but got %+v", a)
+		}
+	})
+
+	t.Run("should not take municipalities for prefectures", func(t *testing.T) {
+		for _, s := range []string{"京都市左京区", "府中市宮西町", "横浜市都筑区", "太宰府市観世音寺4-6-1"} {
+			if a, err := Parse(s); err != nil || a.Prefecture != "" {
+				t.Errorf("expected no prefecture in %s, but got %q and %v", s, a.Prefecture, err)
+			}
+		}
+	})
+
+	t.Run("should normalize addresses", func(t *testing.T) {
+		if got := Normalize("〒１００ー８９７７　東京都千代田区霞が関２丁目１番１号　中央合同庁舎"); got != "〒100-8977 東京都千代田区霞が関2-1-1 中央合同庁舎" {
+			t.Errorf("expected the normalized address, but got %q", got)
+		}
+	})
+}
+
+func TestParseKanjiNumeral(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected int
+		ok       bool
+	}{
+		{"一", 1, true},
+		{"十", 10, true},
+		{"十一", 11, true},
+		{"二十三", 23, true},
+		{"百五", 105, true},
+		{"千二百三十四", 1234, true},
+		{"二〇三", 203, true},
+		{"", 0, false},
+		{"二十a", 0, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			n, ok := ParseKanjiNumeral(tc.input)
+			if n != tc.expected || ok != tc.ok {
+				t.Errorf("expected %d and %v, but got %d and %v", tc.expected, tc.ok, n, ok)
+			}
+		})
+	}
+}
+
+// kenAll is an excerpt of the KEN_ALL.CSV file.
+const kenAll = `13101,"100  ","1000000","ﾄｳｷｮｳﾄ","ﾁﾖﾀﾞｸ","ｲｶﾆｹｲｻｲｶﾞﾅｲﾊﾞｱｲ","東京都","千代田区","以下に掲載がない場合",0,0,0,0,0,0
+13101,"100  ","1000013","ﾄｳｷｮｳﾄ","ﾁﾖﾀﾞｸ","ｶｽﾐｶﾞｾｷ","東京都","千代田区","霞が関",0,0,1,0,0,0
+13101,"100  ","1000005","ﾄｳｷｮｳﾄ","ﾁﾖﾀﾞｸ","ﾏﾙﾉｳﾁ","東京都","千代田区","丸の内（次のビルを除く）",0,0,1,0,0,0
+13101,"100  ","1006990","ﾄｳｷｮｳﾄ","ﾁﾖﾀﾞｸ","ﾏﾙﾉｳﾁｼﾝﾏﾙﾉｳﾁﾋﾞﾙﾃﾞｨﾝｸﾞ","東京都","千代田区","丸の内新丸の内ビルディング（地階・階層不明）",0,0,0,0,0,0
+13206,"183  ","1830000","ﾄｳｷｮｳﾄ","ﾌﾁｭｳｼ","ｲｶﾆｹｲｻｲｶﾞﾅｲﾊﾞｱｲ","東京都","府中市","以下に掲載がない場合",0,0,0,0,0,0
+34208,"72601","7260000","ﾋﾛｼﾏｹﾝ","ﾌﾁｭｳｼ","ｲｶﾆｹｲｻｲｶﾞﾅｲﾊﾞｱｲ","広島県","府中市","以下に掲載がない場合",0,0,0,0,0,0
+10464,"37011","3701101","ｸﾞﾝﾏｹﾝ","ｻﾜｸﾞﾝﾀﾏﾑﾗﾏﾁ","ｲｶﾆｹｲｻｲｶﾞﾅｲﾊﾞｱｲ","群馬県","佐波郡玉村町","以下に掲載がない場合",0,0,0,0,0,0
+01101,"060  ","0600001","ﾎｯｶｲﾄﾞｳ","ｻｯﾎﾟﾛｼﾁｭｳｵｳｸ","ｷﾀ1ｼﾞｮｳﾆｼ(1-19ﾁｮｳﾒ)","北海道","札幌市中央区","北一条西（１～１９丁目）",0,0,1,0,0,0
+02404,"03902","0390224","ｱｵﾓﾘｹﾝ","ｻﾝﾉﾍｸﾞﾝﾅﾝﾌﾞﾁｮｳ","ﾄﾏﾍﾞﾁ","青森県","三戸郡南部町","苫米地（第１地割、第２地割、",0,1,0,0,0,0
+02404,"03902","0390224","ｱｵﾓﾘｹﾝ","ｻﾝﾉﾍｸﾞﾝﾅﾝﾌﾞﾁｮｳ","ﾄﾏﾍﾞﾁ","青森県","三戸郡南部町","第３地割）",0,1,0,0,0,0
+`
+
+func TestDictionary(t *testing.T) {
+	d, err := LoadKenAll(strings.NewReader(kenAll))
+	if err != nil {
+		t.Fatalf("failed to load dataset: %v", err)
+	}
+
+	t.Run("should load Shift_JIS files", func(t *testing.T) {
+		sjis, err := japanese.ShiftJIS.NewEncoder().String(kenAll)
+		if err != nil {
+			t.Fatalf("failed to encode dataset: %v", err)
+		}
+		d, err := LoadKenAll(strings.NewReader(sjis))
+		if err != nil {
+			t.Fatalf("failed to load dataset: %v", err)
+		}
+		if entries := d.Lookup("1000013"); len(entries) != 1 || entries[0].Town != "霞が関" {
+			t.Errorf("expected 霞が関, but got %+v", entries)
+		}
+	})
+
+	t.Run("should look up postal codes", func(t *testing.T) {
+		expected := Entry{PostalCode: "100-0005", Prefecture: "東京都", City: "千代田区", Town: "丸の内"}
+		if entries := d.Lookup("〒１００－０００５"); len(entries) != 1 || entries[0] != expected {
+			t.Errorf("expected %+v, but got %+v", expected, entries)
+		}
+		if entries := d.Lookup("039-0224"); len(entries) != 1 || entries[0].Town != "苫米地" {
+			t.Errorf("expected the split rows to be joined, but got %+v", entries)
+		}
+		if entries := d.Lookup("100-0000"); len(entries) != 1 || entries[0].Town != "" {
+			t.Errorf("expected an empty town, but got %+v", entries)
+		}
+		if entries := d.Lookup("999-9999"); len(entries) != 0 {
+			t.Errorf("expected no entries, but got %+v", entries)
+		}
+	})
+
+	t.Run("should fill in postal codes and prefectures", func(t *testing.T) {
+		testCases := []struct {
+			input    string
+			expected Address
+		}{
+			{"東京都千代田区霞が関2-1-1", Address{PostalCode: "100-0013", Prefecture: "東京都", City: "千代田区", Town: "霞が関", Block: "2-1-1"}},
+			{"千代田区丸の内1-1-1", Address{PostalCode: "100-0005", Prefecture: "東京都", City: "千代田区", Town: "丸の内", Block: "1-1-1"}},
+			{"東京都千代田区永田町1-7-1", Address{PostalCode: "100-0000", Prefecture: "東京都", City: "千代田区", Town: "永田町", Block: "1-7-1"}},
+			{"群馬県佐波郡玉村町下新田201", Address{PostalCode: "370-1101", Prefecture: "群馬県", City: "佐波郡玉村町", Town: "下新田", Block: "201"}},
+		}
+
+		for _, tc := range testCases {
+			t.Run(tc.input, func(t *testing.T) {
+				a, err := d.Parse(tc.input)
+				if err != nil {
+					t.Fatalf("expected no error, but got %v", err)
+				}
+				if a != tc.expected {
+					t.Errorf("expected %+v, but got %+v", tc.expected, a)
+				}
+			})
+		}
+	})
+
+	t.Run("should not guess the prefecture of municipalities in several prefectures", func(t *testing.T) {
+		a, err := d.Parse("府中市府中町1")
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if a.Prefecture != "" || a.City != "府中市" || a.PostalCode != "" {
+			t.Errorf("expected no prefecture and postal code, but got %+v", a)
+		}
+		if a, _ := d.Parse("〒726-0000 府中市府中町1"); a.Prefecture != "広島県" {
+			t.Errorf("expected the prefecture of the postal code, but got %+v", a)
+		}
+	})
+
+	t.Run("should verify postal codes", func(t *testing.T) {
+		if _, err := d.Parse("〒100-0013 東京都千代田区霞が関2-1-1"); err != nil {
+			t.Errorf("expected no error, but got %v", err)
+		}
+		if _, err := d.Parse("〒100-6990 千代田区丸の内1-5-1"); err != nil {
+			t.Errorf("expected the code of a building to cover its town, but got %v", err)
+		}
+		if _, err := d.Parse("〒100-0005 東京都千代田区霞が関2-1-1"); !errors.Is(err, ErrPostalCodeMismatch) {
+			t.Errorf("expected ErrPostalCodeMismatch, but got %v", err)
+		}
+		if _, err := d.Parse("〒999-9999 東京都千代田区霞が関2-1-1"); !errors.Is(err, ErrUnknownPostalCode) {
+			t.Errorf("expected ErrUnknownPostalCode, but got %v", err)
+		}
+	})
+
+	t.Run("should reject malformed files", func(t *testing.T) {
+		if _, err := LoadKenAll(strings.NewReader("13101,\"100  \",\"1000013\"\n")); err == nil {
+			t.Error("expected an error for missing columns, but got nil")
+		}
+	})
+}
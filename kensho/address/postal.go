@@ -0,0 +1,255 @@
+package address
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/unicode/norm"
+)
+
+// ErrUnknownPostalCode is returned when a postal code is not in the dataset.
+var ErrUnknownPostalCode = errors.New("unknown postal code")
+
+// ErrPostalCodeMismatch is returned when the postal code of an address does not cover it.
+var ErrPostalCodeMismatch = errors.New("postal code does not match the address")
+
+// Entry is a postal code and the area it covers.
+type Entry struct {
+	// PostalCode is the postal code in the form 100-0013.
+	PostalCode string
+	Prefecture string
+	City       string
+	// Town is empty when the code covers the areas of the municipality that have no code
+	// of their own.
+	Town string
+}
+
+// Dictionary is an offline postal code dataset, used to split addresses exactly and to
+// fill in or verify their postal codes.
+type Dictionary struct {
+	byCode map[string][]Entry
+	// byCity holds the entries of each municipality, by prefecture and municipality.
+	byCity map[string][]Entry
+	// cities holds the municipalities of each prefecture, longest first.
+	cities map[string][]string
+}
+
+// kenAllColumns is the number of columns of the KEN_ALL.CSV file.
+const kenAllColumns = 15
+
+// kenAllCodeRegex matches a postal code of the KEN_ALL.CSV file.
+var kenAllCodeRegex = regexp.MustCompile(`^[0-9]{7}$`)
+
+// LoadKenAll loads the KEN_ALL.CSV file of the postal codes of Japan published by Japan
+// Post, in Shift_JIS as distributed or converted to UTF-8. Rows split because of the length
+// of the town are joined, and the notes of the town column, such as 以下に掲載がない場合
+// and the parts in parentheses, are removed.
+func LoadKenAll(r io.Reader) (*Dictionary, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read postal code dataset: %w", err)
+	}
+	if !utf8.Valid(data) {
+		if data, err = japanese.ShiftJIS.NewDecoder().Bytes(data); err != nil {
+			return nil, fmt.Errorf("failed to decode postal code dataset: %w", err)
+		}
+	}
+
+	d := &Dictionary{
+		byCode: make(map[string][]Entry),
+		byCity: make(map[string][]Entry),
+		cities: make(map[string][]string),
+	}
+	cr := csv.NewReader(bytes.NewReader(data))
+	cr.FieldsPerRecord = -1
+	var pending *Entry
+	for line := 1; ; line++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse postal code dataset: %w", err)
+		}
+		if len(record) < kenAllColumns {
+			return nil, fmt.Errorf("line %d: has %d columns instead of %d", line, len(record), kenAllColumns)
+		}
+		if !kenAllCodeRegex.MatchString(record[2]) {
+			return nil, fmt.Errorf("line %d: invalid postal code %q", line, record[2])
+		}
+
+		town := norm.NFKC.String(record[8])
+		if pending != nil {
+			pending.Town += town
+		} else {
+			pending = &Entry{
+				PostalCode: record[2][:3] + "-" + record[2][3:],
+				Prefecture: norm.NFKC.String(record[6]),
+				City:       norm.NFKC.String(record[7]),
+				Town:       town,
+			}
+		}
+		if strings.Count(pending.Town, "(") > strings.Count(pending.Town, ")") {
+			continue
+		}
+		pending.Town = kenAllTown(pending.Town)
+		d.add(*pending)
+		pending = nil
+	}
+	if pending != nil {
+		return nil, fmt.Errorf("unterminated town %q for postal code %s", pending.Town, pending.PostalCode)
+	}
+
+	for p, cities := range d.cities {
+		sort.SliceStable(cities, func(i, j int) bool { return len(cities[i]) > len(cities[j]) })
+		d.cities[p] = cities
+	}
+	return d, nil
+}
+
+// parenthesesRegex matches the notes in parentheses of the town column.
+var parenthesesRegex = regexp.MustCompile(`\(.*?\)`)
+
+// kenAllTown removes the notes of the town column of the KEN_ALL.CSV file.
+func kenAllTown(town string) string {
+	switch {
+	case town == "以下に掲載がない場合",
+		strings.HasSuffix(town, "の次に番地がくる場合"),
+		strings.HasSuffix(town, "一円") && town != "一円":
+		return ""
+	}
+	return strings.TrimSpace(parenthesesRegex.ReplaceAllString(town, ""))
+}
+
+// cityKey returns the key of a municipality in byCity.
+func cityKey(prefecture, city string) string {
+	return prefecture + "/" + city
+}
+
+// add adds an entry to the dictionary.
+func (d *Dictionary) add(e Entry) {
+	d.byCode[e.PostalCode] = append(d.byCode[e.PostalCode], e)
+	key := cityKey(e.Prefecture, e.City)
+	if _, ok := d.byCity[key]; !ok {
+		d.cities[e.Prefecture] = append(d.cities[e.Prefecture], e.City)
+	}
+	d.byCity[key] = append(d.byCity[key], e)
+}
+
+// postalCodeReplacer removes the notations around a postal code.
+var postalCodeReplacer = strings.NewReplacer("〒", "", " ", "", "-", "", "ー", "", "−", "")
+
+// Lookup returns the areas covered by a postal code, written with or without a hyphen.
+func (d *Dictionary) Lookup(postalCode string) []Entry {
+	code := postalCodeReplacer.Replace(norm.NFKC.String(postalCode))
+	if !kenAllCodeRegex.MatchString(code) {
+		return nil
+	}
+	return d.byCode[code[:3]+"-"+code[3:]]
+}
+
+// Parse splits an address like Parse, using the municipalities and towns of the dataset.
+// A missing prefecture is filled in from the municipality or the postal code. A missing
+// postal code is filled in when a single one covers the town. A postal code that is not
+// in the dataset or does not cover the address is reported as ErrUnknownPostalCode or
+// ErrPostalCodeMismatch, along with the parts of the address.
+func (d *Dictionary) Parse(s string) (Address, error) {
+	a, err := parse(s, d)
+	if err != nil {
+		return a, err
+	}
+	if a.PostalCode == "" {
+		a.PostalCode = d.postalCode(a)
+		return a, nil
+	}
+
+	entries := d.Lookup(a.PostalCode)
+	if len(entries) == 0 {
+		return a, fmt.Errorf("%w: %s", ErrUnknownPostalCode, a.PostalCode)
+	}
+	for _, e := range entries {
+		if e.Prefecture == a.Prefecture && e.City == a.City &&
+			(e.Town == "" || strings.HasPrefix(e.Town, a.Town) || strings.HasPrefix(a.Town, e.Town)) {
+			return a, nil
+		}
+	}
+	return a, fmt.Errorf("%w: %s is %s%s%s", ErrPostalCodeMismatch, a.PostalCode, entries[0].Prefecture, entries[0].City, entries[0].Town)
+}
+
+// cutCity removes the municipality from the start of s, filling in the prefecture from
+// the municipality or the postal code when it is missing.
+func (d *Dictionary) cutCity(prefecture, postalCode, s string) (string, string, string) {
+	candidates := []string{prefecture}
+	if prefecture == "" {
+		candidates = nil
+		for _, e := range d.Lookup(postalCode) {
+			candidates = append(candidates, e.Prefecture)
+		}
+		if len(candidates) == 0 {
+			candidates = Prefectures
+		}
+	}
+
+	var matchPrefecture, matchCity string
+	for _, p := range candidates {
+		for _, c := range d.cities[p] {
+			if !strings.HasPrefix(s, c) {
+				continue
+			}
+			if matchCity != "" && p != matchPrefecture {
+				// The municipality exists in several prefectures, e.g. 府中市.
+				return prefecture, "", s
+			}
+			matchPrefecture, matchCity = p, c
+			break
+		}
+	}
+	if matchCity == "" {
+		return prefecture, "", s
+	}
+	return matchPrefecture, matchCity, strings.TrimSpace(s[len(matchCity):])
+}
+
+// cutTown removes the longest town of the municipality from the start of s.
+func (d *Dictionary) cutTown(prefecture, city, s string) (string, string) {
+	town := ""
+	for _, e := range d.byCity[cityKey(prefecture, city)] {
+		if len(e.Town) > len(town) && strings.HasPrefix(s, e.Town) {
+			town = e.Town
+		}
+	}
+	return town, strings.TrimSpace(s[len(town):])
+}
+
+// postalCode returns the postal code of the town of an address, or an empty string if
+// it has none or several.
+func (d *Dictionary) postalCode(a Address) string {
+	var codes []string
+	town := ""
+	for _, e := range d.byCity[cityKey(a.Prefecture, a.City)] {
+		if !strings.HasPrefix(a.Town, e.Town) || len(e.Town) < len(town) {
+			continue
+		}
+		if len(e.Town) > len(town) {
+			codes, town = nil, e.Town
+		}
+		codes = append(codes, e.PostalCode)
+	}
+	if len(codes) == 0 {
+		return ""
+	}
+	for _, c := range codes[1:] {
+		if c != codes[0] {
+			return ""
+		}
+	}
+	return codes[0]
+}
@@ -0,0 +1,109 @@
+package kensho
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// BatchOptions controls how ExtractBatch dispatches its requests.
+type BatchOptions struct {
+	// Concurrency is the number of requests extracted at the same time. Values below 1 mean 1.
+	Concurrency int
+	// RequestsPerMinute limits the rate at which requests are dispatched. Zero means no limit.
+	RequestsPerMinute int
+}
+
+// BatchResult is the outcome of one request of a batch.
+type BatchResult struct {
+	// Index is the position of the request in the batch.
+	Index  int
+	Result *ExtractionResult
+	Err    error
+}
+
+// ExtractBatch extracts the documents of reqs with a pool of workers and returns one
+// result per request, in the order of reqs. A failed request does not stop the batch;
+// its error is reported in its result. When ctx is canceled, no further requests are
+// dispatched and ExtractBatch returns the context error once the requests in flight
+// have finished. Requests that were not dispatched report the context error as well.
+// The deadline of ctx, if any, also applies to the requests in flight.
+func (c *Client) ExtractBatch(ctx context.Context, reqs []ExtractRequest, opts BatchOptions) ([]BatchResult, error) {
+	concurrency := opts.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	var interval time.Duration
+	if opts.RequestsPerMinute > 0 {
+		interval = time.Minute / time.Duration(opts.RequestsPerMinute)
+	}
+
+	results := make([]BatchResult, len(reqs))
+	for i := range results {
+		results[i].Index = i
+	}
+
+	// Requests in flight are not canceled with ctx so that they can finish, but they
+	// still end at its deadline.
+	itemCtx := context.WithoutCancel(ctx)
+	if deadline, ok := ctx.Deadline(); ok {
+		var cancel context.CancelFunc
+		itemCtx, cancel = context.WithDeadline(itemCtx, deadline)
+		defer cancel()
+	}
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	next := time.Now()
+
+	dispatched := 0
+	for ; dispatched < len(reqs); dispatched++ {
+		if err := waitUntil(ctx, next); err != nil {
+			break
+		}
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		if interval > 0 {
+			next = time.Now().Add(interval)
+		}
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i].Result, results[i].Err = c.ExtractDocument(itemCtx, reqs[i])
+		}(dispatched)
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		for i := dispatched; i < len(reqs); i++ {
+			results[i].Err = err
+		}
+		return results, err
+	}
+	return results, nil
+}
+
+// waitUntil blocks until t or until ctx is done, whichever comes first.
+func waitUntil(ctx context.Context, t time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	d := time.Until(t)
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,108 @@
+package kensho
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DocumentTypeAuto is the document type that makes ExtractDocument classify the
+// document before extracting it.
+const DocumentTypeAuto = "auto"
+
+// unknownDocumentType is returned by the model when no configured document type matches.
+const unknownDocumentType = "unknown"
+
+// Classification is the document type detected from the submitted images.
+type Classification struct {
+	DocumentType    string  `json:"document_type"`
+	ConfidenceScore float64 `json:"confidence_score"`
+}
+
+// Classify asks the model which of the configured document types the files show and
+// returns the best matching key of Config.Documents.
+func (c *Client) Classify(ctx context.Context, fileParts map[string]FilePart) (*Classification, error) {
+	rec := &usageRecorder{prices: c.prices}
+	classification, err := c.classify(withUsageRecorder(ctx, rec), fileParts, ExtractOptions{})
+
+	usage := rec.snapshot()
+	docType := unknownDocumentType
+	if classification != nil {
+		docType = classification.DocumentType
+	}
+	c.usage.addClassification(docType, &usage, err != nil)
+	return classification, err
+}
+
+func (c *Client) classify(ctx context.Context, fileParts map[string]FilePart, opts ExtractOptions) (*Classification, error) {
+	docTypes := make([]string, 0, len(c.config.Documents))
+	for docType := range c.config.Documents {
+		docTypes = append(docTypes, docType)
+	}
+	sort.Strings(docTypes)
+
+	partNames := make([]string, 0, len(fileParts))
+	for name := range fileParts {
+		partNames = append(partNames, name)
+	}
+	sort.Strings(partNames)
+
+	fileContents, err := c.buildFileParts(fileParts, partNames, opts.Preprocess)
+	if err != nil {
+		return nil, err
+	}
+	prompt := append([]Part{TextPart(classificationPrompt(docTypes, c.config.Documents))}, fileContents...)
+
+	resp, err := c.generate(ctx, &ModelRequest{
+		Model:          opts.Model,
+		Parts:          prompt,
+		ResponseSchema: classificationSchema(docTypes),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to classify document: %w", err)
+	}
+
+	cleaned := sanitizeJSONResponse(resp.Text)
+	var classification Classification
+	if err := json.Unmarshal([]byte(cleaned), &classification); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal classification from response: %w (raw response: %s)", err, cleaned)
+	}
+
+	if _, ok := c.config.Documents[classification.DocumentType]; !ok {
+		return nil, fmt.Errorf("%w: classified as %q", ErrUnsupportedDocumentType, classification.DocumentType)
+	}
+	return &classification, nil
+}
+
+// classificationPrompt lists the candidate document types for the model.
+func classificationPrompt(docTypes []string, documents map[string]Document) string {
+	var b strings.Builder
+	b.WriteString("**Role**: You are an expert in Japanese identity and certificate documents.\n")
+	b.WriteString("**Task**: Identify which of the following document types is shown in the provided images.\n\n")
+	b.WriteString("**Document Types**:\n")
+	for _, docType := range docTypes {
+		if desc := documents[docType].Description; desc != "" {
+			fmt.Fprintf(&b, "- %s: %s\n", docType, desc)
+		} else {
+			fmt.Fprintf(&b, "- %s\n", docType)
+		}
+	}
+	fmt.Fprintf(&b, "\nIf none of them matches, answer %q.\n", unknownDocumentType)
+	b.WriteString("Return **only** a single, minified JSON object: ")
+	b.WriteString(`{ "document_type": "key of the document type", "confidence_score": 0.0-1.0 }`)
+	return b.String()
+}
+
+// classificationSchema restricts the classification response to the candidate document types.
+func classificationSchema(docTypes []string) *Schema {
+	return &Schema{
+		Type: SchemaTypeObject,
+		Properties: map[string]*Schema{
+			"document_type":    {Type: SchemaTypeString, Enum: append(append([]string{}, docTypes...), unknownDocumentType)},
+			"confidence_score": {Type: SchemaTypeNumber, Description: "0.0-1.0"},
+		},
+		Required: []string{"document_type", "confidence_score"},
+	}
+}
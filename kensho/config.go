@@ -3,14 +3,108 @@ package kensho
 import (
 	"fmt"
 	"os"
+	"regexp"
+
+	"github.com/y-mitsuyoshi/kensho/kensho/validation"
 
 	"gopkg.in/yaml.v3"
 )
 
 type Document struct {
-	Prompt        string            `yaml:"prompt"`
-	JSONStructure map[string]string `yaml:"json_structure"`
-	ImageParts    []string          `yaml:"image_parts"`
+	Description   string               `yaml:"description"`
+	Prompt        string               `yaml:"prompt"`
+	JSONStructure map[string]FieldSpec `yaml:"json_structure"`
+	ImageParts    []string             `yaml:"image_parts"`
+	// Models overrides the model chain of the client for this document type.
+	Models []string `yaml:"models"`
+	// Escalation overrides the escalation policy of the client for this document type.
+	Escalation *EscalationPolicy `yaml:"escalation"`
+	// Rules lists the cross-field consistency checks of the document type.
+	Rules []RuleSpec `yaml:"rules"`
+}
+
+// RuleSpec configures a cross-field rule of the validation package on some fields of a
+// document, e.g. `{ rule: date_order, fields: [birth_date, issue_date, expiry_date] }`.
+type RuleSpec struct {
+	Rule   string   `yaml:"rule"`
+	Fields []string `yaml:"fields"`
+	// Severity overrides the severity of a failure of the rule.
+	Severity validation.Severity `yaml:"severity"`
+}
+
+// FieldType is the type of the value of a field.
+type FieldType string
+
+const (
+	FieldTypeString FieldType = "string"
+	FieldTypeDate   FieldType = "date"
+	FieldTypeNumber FieldType = "number"
+	FieldTypeEnum   FieldType = "enum"
+	FieldTypeBool   FieldType = "bool"
+)
+
+// FieldSpec describes a field of the JSON structure of a document type. In YAML it is
+// either a label, as in `name: "氏名"`, or a mapping with the keys below.
+type FieldSpec struct {
+	// Label is the Japanese name of the field shown to the model.
+	Label string `yaml:"label"`
+	// Type is the type the value is coerced to. An empty type keeps the value as returned
+	// by the model.
+	Type FieldType `yaml:"type"`
+	// Optional fields are not re-requested from the model when they are missing. It is
+	// set by `required: false` in YAML; fields are required by default.
+	Optional bool `yaml:"-"`
+	// Enum lists the allowed values of an enum field.
+	Enum []string `yaml:"enum"`
+	// Format is a regular expression string values must match.
+	Format string `yaml:"format"`
+	// Sensitive fields are masked when masking is requested.
+	Sensitive bool `yaml:"sensitive"`
+	// Validators lists the names of the validators of the validation package run on the value.
+	Validators []string `yaml:"validators"`
+	// Normalize lists the normalizations applied in order to the value to obtain its
+	// normalized form, which is what validators and rules check.
+	Normalize []Normalization `yaml:"normalize"`
+}
+
+// UnmarshalYAML accepts both the label-only and the mapping form of a field.
+func (s *FieldSpec) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*s = FieldSpec{Label: value.Value}
+		return nil
+	}
+
+	type plain FieldSpec
+	var spec struct {
+		plain    `yaml:",inline"`
+		Required *bool `yaml:"required"`
+	}
+	if err := value.Decode(&spec); err != nil {
+		return err
+	}
+	*s = FieldSpec(spec.plain)
+	s.Optional = spec.Required != nil && !*spec.Required
+
+	switch s.Type {
+	case "", FieldTypeString, FieldTypeDate, FieldTypeNumber, FieldTypeBool:
+	case FieldTypeEnum:
+		if len(s.Enum) == 0 {
+			return fmt.Errorf("line %d: enum field without enum values", value.Line)
+		}
+	default:
+		return fmt.Errorf("line %d: unknown field type %q", value.Line, s.Type)
+	}
+	for _, n := range s.Normalize {
+		if _, ok := normalizers[n]; !ok {
+			return fmt.Errorf("line %d: unknown normalization %q", value.Line, n)
+		}
+	}
+	if s.Format != "" {
+		if _, err := regexp.Compile(s.Format); err != nil {
+			return fmt.Errorf("line %d: invalid format: %w", value.Line, err)
+		}
+	}
+	return nil
 }
 
 type Config struct {
@@ -0,0 +1,101 @@
+package kensho
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/y-mitsuyoshi/kensho/kensho/validation"
+)
+
+// Consensus summarizes how the samples of an extraction with ExtractOptions.Samples
+// agreed with each other.
+type Consensus struct {
+	// Samples is the number of model responses that were merged.
+	Samples int `json:"samples"`
+	// DisputedFields lists the fields on which the samples did not all agree.
+	DisputedFields []string `json:"disputed_fields,omitempty"`
+}
+
+// vote is a distinct value of a field among the samples.
+type vote struct {
+	field      Field
+	count      int
+	confidence float64
+}
+
+// mergeSamples merges the fields of several model responses to the same prompt by
+// majority vote. Ties go to the value with the higher total confidence. The confidence
+// score of a merged field is the mean confidence of its majority scaled by the share of
+// samples that returned it, which is also reported as Field.Agreement. Samples lacking
+// a field count as disagreeing with every value. Dates declared in the JSON structure of
+// doc are compared by the day they denote.
+func mergeSamples(doc Document, samples []map[string]Field) (map[string]Field, *Consensus) {
+	names := make(map[string]bool)
+	for _, data := range samples {
+		for name := range data {
+			names[name] = true
+		}
+	}
+
+	merged := make(map[string]Field, len(names))
+	consensus := &Consensus{Samples: len(samples)}
+	for name := range names {
+		var votes []*vote
+		index := make(map[string]*vote)
+		for _, data := range samples {
+			field, ok := data[name]
+			if !ok {
+				continue
+			}
+			key := voteKey(doc.JSONStructure[name], field)
+			v, ok := index[key]
+			if !ok {
+				v = &vote{field: field}
+				index[key] = v
+				votes = append(votes, v)
+			}
+			v.count++
+			v.confidence += field.ConfidenceScore
+		}
+
+		best := votes[0]
+		for _, v := range votes[1:] {
+			if v.count > best.count || (v.count == best.count && v.confidence > best.confidence) {
+				best = v
+			}
+		}
+
+		agreement := float64(best.count) / float64(len(samples))
+		field := best.field
+		field.ConfidenceScore = best.confidence / float64(best.count) * agreement
+		field.Agreement = agreement
+		merged[name] = field
+		if best.count < len(samples) {
+			consensus.DisputedFields = append(consensus.DisputedFields, name)
+		}
+	}
+	sort.Strings(consensus.DisputedFields)
+	return merged, consensus
+}
+
+// voteKey returns the value of a field compared between samples: the day of a date field
+// written in any supported notation, its normalized form when it has one, and its value
+// otherwise. Surrounding whitespace of strings is ignored.
+func voteKey(spec FieldSpec, field Field) string {
+	if text, ok := field.text(); ok && spec.Type == FieldTypeDate {
+		if t, err := validation.ParseJapaneseDate(text); err == nil {
+			return "d:" + t.Format("2006-01-02")
+		}
+	}
+	if field.Normalized != "" {
+		return "n:" + field.Normalized
+	}
+	switch v := field.Value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "s:" + strings.TrimSpace(v)
+	}
+	return fmt.Sprintf("%T:%v", field.Value, field.Value)
+}
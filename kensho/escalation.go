@@ -0,0 +1,90 @@
+package kensho
+
+import "github.com/y-mitsuyoshi/kensho/kensho/validation"
+
+// EscalationPolicy decides when an extraction is retried with the next model of the
+// model chain, e.g. from gemini-2.5-flash to gemini-2.5-pro.
+type EscalationPolicy struct {
+	// MinAverageConfidence escalates when the average confidence score of the extracted
+	// fields with a value is below this value. Zero disables the check.
+	MinAverageConfidence float64 `yaml:"min_average_confidence"`
+	// ValidatedFields escalates when one of these fields fails validation.
+	ValidatedFields []string `yaml:"validated_fields"`
+	// OnError escalates when the extraction with a model fails.
+	OnError bool `yaml:"on_error"`
+}
+
+// DefaultEscalationPolicy is the escalation policy of clients created with NewClient and its variants.
+var DefaultEscalationPolicy = EscalationPolicy{
+	MinAverageConfidence: 0.7,
+	ValidatedFields:      []string{"card_number"},
+	OnError:              true,
+}
+
+// shouldEscalate reports whether result is not good enough to be returned.
+func (p EscalationPolicy) shouldEscalate(result *ExtractionResult) bool {
+	if p.MinAverageConfidence > 0 && averageConfidence(result.ExtractedData) < p.MinAverageConfidence {
+		return true
+	}
+	for _, name := range p.ValidatedFields {
+		if field, ok := result.ExtractedData[name]; ok && failedValidation(field) {
+			return true
+		}
+	}
+	return false
+}
+
+// averageConfidence returns the mean confidence score of the fields of data that have a
+// value, or zero when there are none. Fields the model left blank, such as optional
+// fields missing from the document, do not lower the average.
+func averageConfidence(data map[string]Field) float64 {
+	var sum float64
+	n := 0
+	for _, field := range data {
+		if field.Value == nil || field.Value == "" {
+			continue
+		}
+		sum += field.ConfidenceScore
+		n++
+	}
+	if n == 0 {
+		return 0
+	}
+	return sum / float64(n)
+}
+
+// failedValidation reports whether field failed a validation of error severity. Fields
+// without structured results fall back to their validation summary.
+func failedValidation(field Field) bool {
+	if len(field.Validations) == 0 {
+		return field.Validation == string(validation.StatusInvalid)
+	}
+	for _, result := range field.Validations {
+		if result.Status == validation.StatusInvalid && result.Severity == validation.SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// modelChain returns the models to try, in order, for an extraction of doc. An empty
+// model name stands for the default model of the backend.
+func (c *Client) modelChain(doc Document, opts ExtractOptions) []string {
+	switch {
+	case opts.Model != "":
+		return []string{opts.Model}
+	case len(doc.Models) > 0:
+		return doc.Models
+	case len(c.models) > 0:
+		return c.models
+	}
+	return []string{""}
+}
+
+// escalationPolicy returns the escalation policy for doc.
+func (c *Client) escalationPolicy(doc Document) EscalationPolicy {
+	if doc.Escalation != nil {
+		return *doc.Escalation
+	}
+	return c.escalation
+}
@@ -0,0 +1,130 @@
+package kensho
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/y-mitsuyoshi/kensho/kensho/validation"
+)
+
+// boolValues maps the textual booleans returned by models to their values.
+var boolValues = map[string]bool{
+	"true": true, "yes": true, "はい": true, "有": true, "あり": true,
+	"false": false, "no": false, "いいえ": false, "無": false, "なし": false,
+}
+
+// coerceValue converts a value returned by the model to the type of spec and returns an
+// error when it is not of that type. Null values conform to every type, and values of
+// fields without a type are returned unchanged.
+func coerceValue(spec FieldSpec, value interface{}) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+	typeErr := fmt.Errorf("not a valid %s", spec.Type)
+
+	switch spec.Type {
+	case FieldTypeString:
+		if f, ok := value.(float64); ok {
+			value = strconv.FormatFloat(f, 'f', -1, 64)
+		}
+	case FieldTypeDate:
+		// Dates are kept as written on the document; their calendar is checked by validation.
+		if _, ok := value.(string); !ok {
+			return value, typeErr
+		}
+	case FieldTypeNumber:
+		if s, ok := value.(string); ok {
+			f, err := strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(s), ",", ""), 64)
+			if err != nil {
+				return value, typeErr
+			}
+			value = f
+		}
+		if _, ok := value.(float64); !ok {
+			return value, typeErr
+		}
+	case FieldTypeBool:
+		if s, ok := value.(string); ok {
+			b, ok := boolValues[strings.ToLower(strings.TrimSpace(s))]
+			if !ok {
+				return value, typeErr
+			}
+			value = b
+		}
+		if _, ok := value.(bool); !ok {
+			return value, typeErr
+		}
+	case FieldTypeEnum:
+		s, ok := value.(string)
+		if !ok {
+			return value, typeErr
+		}
+		matched := false
+		for _, e := range spec.Enum {
+			if strings.EqualFold(strings.TrimSpace(s), e) {
+				value, matched = e, true
+				break
+			}
+		}
+		if !matched {
+			return value, typeErr
+		}
+	}
+	return value, nil
+}
+
+// matchesFormat reports whether value matches the format of spec. Fields without a
+// format and null values always match.
+func matchesFormat(spec FieldSpec, value interface{}) bool {
+	if spec.Format == "" || value == nil {
+		return true
+	}
+	s, ok := value.(string)
+	if !ok {
+		return false
+	}
+	matched, err := regexp.MatchString(spec.Format, s)
+	return err == nil && matched
+}
+
+// applyFieldTypes coerces the values of data to the types of the JSON structure of doc
+// and checks their format. Fields whose value does not conform are kept as returned and
+// marked invalid. Dates are normalized to ISO 8601, replacing their normalized text.
+func applyFieldTypes(doc Document, data map[string]Field) {
+	for name, field := range data {
+		spec, ok := doc.JSONStructure[name]
+		if !ok {
+			continue
+		}
+		// Numbers, booleans and enums are read from the normalized form of the value.
+		input := field.Value
+		if text, ok := field.text(); ok && (spec.Type == FieldTypeNumber || spec.Type == FieldTypeBool || spec.Type == FieldTypeEnum) {
+			input = text
+		}
+		value, err := coerceValue(spec, input)
+		if err != nil {
+			value = field.Value
+		}
+		field.Value = value
+
+		// Strings are checked against the format in their normalized form.
+		formatInput := value
+		if _, ok := value.(string); ok && field.Normalized != "" {
+			formatInput = field.Normalized
+		}
+		if err != nil {
+			field.addValidation(ValidationResult{Status: validation.StatusInvalid, Rule: "type", Message: err.Error()})
+		} else if spec.Type == FieldTypeDate && value != nil {
+			text, _ := field.text()
+			if t, err := validation.ParseJapaneseDate(text); err == nil {
+				field.Normalized = t.Format("2006-01-02")
+			}
+		}
+		if err == nil && !matchesFormat(spec, formatInput) {
+			field.addValidation(ValidationResult{Status: validation.StatusInvalid, Rule: "format", Message: "does not match " + spec.Format})
+		}
+		data[name] = field
+	}
+}
@@ -0,0 +1,137 @@
+package kensho
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/google/generative-ai-go/genai"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+)
+
+// geminiModel is a GenerativeModel backed by the Gemini API.
+type geminiModel struct {
+	client    *genai.Client
+	modelName string
+}
+
+func newGeminiModel(ctx context.Context, apiKey, modelName string, httpClient *http.Client) (*geminiModel, error) {
+	opts := []option.ClientOption{option.WithAPIKey(apiKey)}
+	if httpClient != nil {
+		opts = append(opts, option.WithHTTPClient(httpClient))
+	}
+
+	client, err := genai.NewClient(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create genai client: %w", err)
+	}
+	return &geminiModel{client: client, modelName: modelName}, nil
+}
+
+// GenerateContent implements GenerativeModel.
+func (m *geminiModel) GenerateContent(ctx context.Context, req *ModelRequest) (*ModelResponse, error) {
+	modelName := m.modelName
+	if req.Model != "" {
+		modelName = req.Model
+	}
+	model := m.client.GenerativeModel(modelName)
+	if req.ResponseSchema != nil {
+		model.ResponseMIMEType = "application/json"
+		model.ResponseSchema = toGenaiSchema(req.ResponseSchema)
+	}
+
+	parts := make([]genai.Part, 0, len(req.Parts))
+	for _, p := range req.Parts {
+		if p.IsBlob() {
+			parts = append(parts, genai.Blob{MIMEType: p.MIMEType, Data: p.Data})
+		} else {
+			parts = append(parts, genai.Text(p.Text))
+		}
+	}
+
+	resp, err := model.GenerateContent(ctx, parts...)
+	if err != nil {
+		return nil, classifyGeminiError(err)
+	}
+
+	text, err := geminiResponseText(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ModelResponse{Text: text, Model: modelName}
+	if u := resp.UsageMetadata; u != nil {
+		result.Usage = TokenUsage{
+			PromptTokens:     int(u.PromptTokenCount),
+			CandidatesTokens: int(u.CandidatesTokenCount),
+			TotalTokens:      int(u.TotalTokenCount),
+		}
+	}
+	return result, nil
+}
+
+// Close closes the underlying genai client.
+func (m *geminiModel) Close() error {
+	return m.client.Close()
+}
+
+// classifyGeminiError wraps err with the matching sentinel error, if any.
+func classifyGeminiError(err error) error {
+	var blocked *genai.BlockedError
+	if errors.As(err, &blocked) {
+		return fmt.Errorf("%w: %w", ErrSafetyBlocked, err)
+	}
+
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		if sentinel := classifyHTTPStatus(apiErr.Code); sentinel != nil {
+			return fmt.Errorf("%w: %w", sentinel, err)
+		}
+	}
+	return err
+}
+
+// toGenaiSchema converts a Schema into its genai counterpart.
+func toGenaiSchema(s *Schema) *genai.Schema {
+	out := &genai.Schema{
+		Description: s.Description,
+		Nullable:    s.Nullable,
+		Enum:        s.Enum,
+		Required:    s.Required,
+	}
+	switch s.Type {
+	case SchemaTypeObject:
+		out.Type = genai.TypeObject
+	case SchemaTypeNumber:
+		out.Type = genai.TypeNumber
+	case SchemaTypeBoolean:
+		out.Type = genai.TypeBoolean
+	default:
+		out.Type = genai.TypeString
+	}
+	if len(s.Enum) > 0 {
+		out.Format = "enum"
+	}
+	if len(s.Properties) > 0 {
+		out.Properties = make(map[string]*genai.Schema, len(s.Properties))
+		for name, prop := range s.Properties {
+			out.Properties[name] = toGenaiSchema(prop)
+		}
+	}
+	return out
+}
+
+// geminiResponseText returns the text of the first part of the first candidate.
+func geminiResponseText(resp *genai.GenerateContentResponse) (string, error) {
+	if len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil || len(resp.Candidates[0].Content.Parts) == 0 {
+		return "", ErrNoContent
+	}
+
+	text, ok := resp.Candidates[0].Content.Parts[0].(genai.Text)
+	if !ok {
+		return "", fmt.Errorf("unexpected response format from API")
+	}
+	return string(text), nil
+}
@@ -10,10 +10,9 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
-	"github.com/google/generative-ai-go/genai"
 	"github.com/y-mitsuyoshi/kensho/kensho/validation"
-	"google.golang.org/api/option"
 )
 
 // ErrUnsupportedDocumentType is returned when the document type is not supported.
@@ -28,6 +27,10 @@ var ErrRequestBodyTooLarge = errors.New("request body too large")
 // ErrMissingField is returned when a required field is missing from the request.
 var ErrMissingField = errors.New("missing required field")
 
+// MaxSamples is the largest samples value accepted by ParseExtractRequest, since each
+// sample is a separate paid model call.
+const MaxSamples = 5
+
 var supportedMimeTypes = map[string]bool{
 	"image/jpeg":      true,
 	"image/png":       true,
@@ -35,63 +38,116 @@ var supportedMimeTypes = map[string]bool{
 	"application/pdf": true,
 }
 
-// GenerativeModel is an interface that abstracts the genai.GenerativeModel.
-type GenerativeModel interface {
-	GenerateContent(ctx context.Context, parts ...genai.Part) (*genai.GenerateContentResponse, error)
-}
-
-// Client holds the genai client and configuration.
+// Client holds the model backend and configuration.
 type Client struct {
-	genaiClient     *genai.Client
 	generativeModel GenerativeModel
 	config          *Config
+	retryPolicy     RetryPolicy
+	repairAttempts  int
+	models          []string
+	escalation      EscalationPolicy
+	prices          PriceTable
+	usage           usageCounters
+	validators      *validation.Registry
+	// clock returns the current time the validity of documents is checked against.
+	// Nil means time.Now.
+	clock func() time.Time
+	// expiryWarningDays is the number of days before expiry from which a document is
+	// reported as expiring. Zero disables the warning.
+	expiryWarningDays int
+	// legacyValidation omits the structured validation results from extraction results.
+	legacyValidation bool
 }
 
-// NewClient creates a new client for the Gemini API using the default embedded configuration.
-func NewClient(ctx context.Context, apiKey string, modelName string) (*Client, error) {
+// NewClient creates a new client using the default embedded configuration.
+// The Gemini API is used unless another provider is selected with WithProvider.
+func NewClient(ctx context.Context, apiKey string, modelName string, opts ...ClientOption) (*Client, error) {
 	config, err := loadDefaultConfig()
 	if err != nil {
 		return nil, fmt.Errorf("failed to load default config: %w", err)
 	}
-	return NewClientWithConfig(ctx, apiKey, modelName, *config)
+	return NewClientWithConfig(ctx, apiKey, modelName, *config, opts...)
 }
 
-// NewClientWithConfigPath creates a new client for the Gemini API using a configuration file from the specified path.
-func NewClientWithConfigPath(ctx context.Context, apiKey string, modelName string, configPath string) (*Client, error) {
+// NewClientWithConfigPath creates a new client using a configuration file from the specified path.
+func NewClientWithConfigPath(ctx context.Context, apiKey string, modelName string, configPath string, opts ...ClientOption) (*Client, error) {
 	config, err := LoadConfig(configPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load config from path %s: %w", configPath, err)
 	}
-	return NewClientWithConfig(ctx, apiKey, modelName, *config)
+	return NewClientWithConfig(ctx, apiKey, modelName, *config, opts...)
 }
 
-// NewClientWithConfig creates a new client for the Gemini API with a provided configuration struct.
-func NewClientWithConfig(ctx context.Context, apiKey string, modelName string, config Config) (*Client, error) {
-	if apiKey == "" {
-		return nil, fmt.Errorf("GEMINI_API_KEY is not set")
+// NewClientWithConfig creates a new client with a provided configuration struct.
+func NewClientWithConfig(ctx context.Context, apiKey string, modelName string, config Config, opts ...ClientOption) (*Client, error) {
+	o := clientOptions{
+		provider:       ProviderGemini,
+		retryPolicy:    DefaultRetryPolicy,
+		repairAttempts: DefaultRepairAttempts,
+		escalation:     DefaultEscalationPolicy,
+		prices:         DefaultPriceTable,
+		expiryWarning:  DefaultExpiryWarningDays,
+	}
+	for _, opt := range opts {
+		opt(&o)
 	}
 
-	client, err := genai.NewClient(ctx, option.WithAPIKey(apiKey))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create genai client: %w", err)
+	registry := o.validators
+	if registry == nil {
+		registry = validation.DefaultRegistry
+	}
+	if err := checkValidators(config, registry); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
 	}
 
-	if modelName == "" {
-		modelName = "gemini-2.5-pro"
+	model := o.model
+	if model == nil {
+		switch o.provider {
+		case ProviderGemini:
+			if apiKey == "" {
+				return nil, fmt.Errorf("GEMINI_API_KEY is not set")
+			}
+			if modelName == "" {
+				modelName = "gemini-2.5-pro"
+			}
+			gemini, err := newGeminiModel(ctx, apiKey, modelName, o.httpClient)
+			if err != nil {
+				return nil, err
+			}
+			model = gemini
+		case ProviderOpenAI:
+			if modelName == "" {
+				return nil, fmt.Errorf("model name is required for provider %s", o.provider)
+			}
+			model = newOpenAIModel(o.baseURL, apiKey, modelName, o.httpClient)
+		default:
+			return nil, fmt.Errorf("unknown provider: %s", o.provider)
+		}
 	}
 
-	model := client.GenerativeModel(modelName)
 	return &Client{
-		genaiClient:     client,
-		generativeModel: model,
-		config:          &config,
+		generativeModel:   model,
+		config:            &config,
+		retryPolicy:       o.retryPolicy,
+		repairAttempts:    o.repairAttempts,
+		models:            append([]string{modelName}, o.fallbackModels...),
+		escalation:        o.escalation,
+		prices:            o.prices,
+		validators:        o.validators,
+		clock:             o.clock,
+		expiryWarningDays: o.expiryWarning,
+		legacyValidation:  o.legacyValidation,
 	}, nil
 }
 
-// Close closes the underlying genai client.
+// Close releases the resources held by the model backend.
 func (c *Client) Close() {
-	if err := c.genaiClient.Close(); err != nil {
-		log.Printf("failed to close genai client: %v", err)
+	closer, ok := c.generativeModel.(io.Closer)
+	if !ok {
+		return
+	}
+	if err := closer.Close(); err != nil {
+		log.Printf("failed to close model backend: %v", err)
 	}
 }
 
@@ -101,13 +157,75 @@ type FilePart struct {
 	MimeType string
 }
 
+// ExtractOptions holds the per-call settings of an extraction.
+type ExtractOptions struct {
+	// Masking masks sensitive values such as the card number in the result and leaves out
+	// the raw response of the model.
+	Masking bool
+	// Preprocess applies image preprocessing before the files are sent to the model.
+	Preprocess bool
+	// Timeout bounds the whole extraction. Zero means no additional timeout.
+	Timeout time.Duration
+	// Fields restricts the extracted data to the listed fields. Empty means all fields.
+	Fields []string
+	// Model overrides the model configured on the Client for this call.
+	Model string
+	// Samples runs the extraction that many times per model and merges the responses
+	// field by field by majority vote. Values below 2 disable voting.
+	Samples int
+}
+
+// ExtractRequest describes a single document to extract.
+type ExtractRequest struct {
+	// DocumentType is a key of Config.Documents, or DocumentTypeAuto to classify the
+	// document before extracting it.
+	DocumentType string
+	FileParts    map[string]FilePart
+	Options      ExtractOptions
+}
+
 // Field represents a single extracted field, including its value and confidence score.
 type Field struct {
 	Value           interface{} `json:"value"`
 	ConfidenceScore float64     `json:"confidence_score"`
-	Validation      string      `json:"validation,omitempty"`
+	// Normalized is the canonical form of Value, e.g. an ISO 8601 date for date fields.
+	Normalized string `json:"normalized,omitempty"`
+	// Validation summarizes Validations: invalid when a rule failed, valid when all passed.
+	Validation string `json:"validation,omitempty"`
+	// Validations holds the result of every validation rule run on the field.
+	Validations []ValidationResult `json:"validations,omitempty"`
+	// Agreement is the share of samples that returned Value when ExtractOptions.Samples is set.
+	Agreement float64 `json:"agreement,omitempty"`
+}
+
+// ValidationResult is the outcome of a validation rule on a field.
+type ValidationResult struct {
+	Status validation.Status `json:"status"`
+	// Rule is the name of the validator, or type and format for the field definition checks.
+	Rule     string              `json:"rule"`
+	Message  string              `json:"message,omitempty"`
+	Severity validation.Severity `json:"severity,omitempty"`
+}
+
+// Finding is the failure of a cross-field rule of the document.
+type Finding struct {
+	ValidationResult
+	// Fields lists the fields the rule was run on.
+	Fields []string `json:"fields"`
 }
 
+// Verdict is the overall outcome of the validation of an extraction.
+type Verdict string
+
+const (
+	// VerdictPass means that no validation failed.
+	VerdictPass Verdict = "pass"
+	// VerdictReview means that the result should be checked by a person.
+	VerdictReview Verdict = "review"
+	// VerdictFail means that a validation of error severity failed.
+	VerdictFail Verdict = "fail"
+)
+
 // ForgeryWarning contains information about potential document forgery.
 type ForgeryWarning struct {
 	HasSignsOfForgery bool   `json:"has_signs_of_forgery"`
@@ -116,34 +234,91 @@ type ForgeryWarning struct {
 
 // ExtractionResult represents the overall result of the extraction process.
 type ExtractionResult struct {
+	DocumentType   string          `json:"document_type,omitempty"`
+	Classification *Classification `json:"classification,omitempty"`
+	// Model is the model that produced the result.
+	Model          string           `json:"model,omitempty"`
 	ExtractedData  map[string]Field `json:"extracted_data"`
 	ForgeryWarning *ForgeryWarning  `json:"forgery_warning,omitempty"`
-	RawResponse    string           `json:"raw_response,omitempty"`
+	// Consensus reports the agreement between samples when ExtractOptions.Samples is set.
+	Consensus   *Consensus `json:"consensus,omitempty"`
+	RawResponse string     `json:"raw_response,omitempty"`
+	// Findings lists the cross-field rules of the document type that failed.
+	Findings []Finding `json:"findings,omitempty"`
+	// Verdict is the overall outcome of the validation of the extracted data.
+	Verdict Verdict `json:"verdict,omitempty"`
+	// Status tells whether the document is still valid, from its expiry date.
+	Status DocumentStatus `json:"status,omitempty"`
+	// Attempts is the number of model calls needed to obtain a valid and complete response.
+	Attempts int `json:"attempts,omitempty"`
+	// Usage reports the tokens, latency and estimated cost of the extraction.
+	Usage *Usage `json:"usage,omitempty"`
 }
 
 // ParseRequest parses a multipart HTTP request to extract the document type and file parts.
 // It enforces a request body size limit of 100MB.
+//
+// Deprecated: Use ParseExtractRequest, which also parses the remaining extraction options.
 func ParseRequest(r *http.Request) (string, map[string]FilePart, bool, bool, error) {
+	req, err := ParseExtractRequest(r)
+	if err != nil {
+		return "", nil, false, false, err
+	}
+	return req.DocumentType, req.FileParts, req.Options.Masking, req.Options.Preprocess, nil
+}
+
+// ParseExtractRequest parses a multipart HTTP request into an ExtractRequest.
+// It enforces a request body size limit of 100MB.
+func ParseExtractRequest(r *http.Request) (*ExtractRequest, error) {
 	if r.Method != http.MethodPost {
-		return "", nil, false, false, fmt.Errorf("invalid request method: %s", r.Method)
+		return nil, fmt.Errorf("invalid request method: %s", r.Method)
 	}
 
 	// Limit request body to 100MB to avoid OOM
 	r.Body = http.MaxBytesReader(nil, r.Body, 100<<20)
 	if err := r.ParseMultipartForm(100 << 20); err != nil {
 		if err == http.ErrBodyReadAfterClose || err.Error() == "http: request body too large" {
-			return "", nil, false, false, ErrRequestBodyTooLarge
+			return nil, ErrRequestBodyTooLarge
 		}
-		return "", nil, false, false, fmt.Errorf("could not parse multipart form: %w", err)
+		return nil, fmt.Errorf("could not parse multipart form: %w", err)
 	}
 
+	// An omitted document type is detected from the images.
 	docType := r.FormValue("document_type")
 	if docType == "" {
-		return "", nil, false, false, fmt.Errorf("%w: document_type", ErrMissingField)
+		docType = DocumentTypeAuto
+	}
+
+	var opts ExtractOptions
+	opts.Masking, _ = strconv.ParseBool(r.FormValue("masking"))
+	opts.Preprocess, _ = strconv.ParseBool(r.FormValue("preprocess"))
+
+	if v := r.FormValue("timeout"); v != "" {
+		timeout, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timeout %q: %w", v, err)
+		}
+		opts.Timeout = timeout
+	}
+
+	if v := r.FormValue("samples"); v != "" {
+		samples, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid samples %q: %w", v, err)
+		}
+		if samples > MaxSamples {
+			return nil, fmt.Errorf("invalid samples %d: at most %d are allowed", samples, MaxSamples)
+		}
+		opts.Samples = samples
 	}
 
-	masking, _ := strconv.ParseBool(r.FormValue("masking"))
-	preprocess, _ := strconv.ParseBool(r.FormValue("preprocess"))
+	if v := r.FormValue("fields"); v != "" {
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				opts.Fields = append(opts.Fields, name)
+			}
+		}
+	}
 
 	fileParts := make(map[string]FilePart)
 	for name, headers := range r.MultipartForm.File {
@@ -157,13 +332,13 @@ func ParseRequest(r *http.Request) (string, map[string]FilePart, bool, bool, err
 
 		file, err := header.Open()
 		if err != nil {
-			return "", nil, false, false, fmt.Errorf("could not open file part %s: %w", name, err)
+			return nil, fmt.Errorf("could not open file part %s: %w", name, err)
 		}
 		defer file.Close()
 
 		content, err := io.ReadAll(file)
 		if err != nil {
-			return "", nil, false, false, fmt.Errorf("could not read file part %s: %w", name, err)
+			return nil, fmt.Errorf("could not read file part %s: %w", name, err)
 		}
 
 		if len(content) > 0 {
@@ -176,152 +351,301 @@ func ParseRequest(r *http.Request) (string, map[string]FilePart, bool, bool, err
 	}
 
 	if len(fileParts) == 0 {
-		return "", nil, false, false, fmt.Errorf("%w: at least one image is required", ErrMissingField)
+		return nil, fmt.Errorf("%w: at least one image is required", ErrMissingField)
 	}
 
-	return docType, fileParts, masking, preprocess, nil
+	return &ExtractRequest{
+		DocumentType: docType,
+		FileParts:    fileParts,
+		Options:      opts,
+	}, nil
 }
 
 // maskString masks a string, showing only the last 4 characters.
 func maskString(s string) string {
-	if len(s) <= 4 {
+	r := []rune(s)
+	if len(r) <= 4 {
 		return s
 	}
-	return "************" + s[len(s)-4:]
+	return "************" + string(r[len(r)-4:])
 }
 
-// Extract sends one or more files to the Gemini API, asks it to extract information,
+// Extract sends one or more files to the model backend, asks it to extract information,
 // and returns the result as a map.
+//
+// Deprecated: Use ExtractDocument, which accepts the full set of ExtractOptions.
 func (c *Client) Extract(ctx context.Context, fileParts map[string]FilePart, docType string, masking, preprocess bool) (*ExtractionResult, error) {
-	doc, ok := c.config.Documents[docType]
-	if !ok {
-		return nil, fmt.Errorf("%w: %s", ErrUnsupportedDocumentType, docType)
-	}
+	return c.ExtractDocument(ctx, ExtractRequest{
+		DocumentType: docType,
+		FileParts:    fileParts,
+		Options: ExtractOptions{
+			Masking:    masking,
+			Preprocess: preprocess,
+		},
+	})
+}
 
-	prompt := []genai.Part{
-		genai.Text(doc.Prompt),
+// ExtractDocument sends the files of req to the model backend, asks it to extract the
+// information of the requested document type, and returns the result.
+func (c *Client) ExtractDocument(ctx context.Context, req ExtractRequest) (*ExtractionResult, error) {
+	start := time.Now()
+	rec := &usageRecorder{prices: c.prices}
+	result, docType, err := c.extractDocument(withUsageRecorder(ctx, rec), req)
+
+	usage := rec.snapshot()
+	usage.LatencyMS = time.Since(start).Milliseconds()
+	if result != nil {
+		usage.Model = result.Model
+		result.Usage = &usage
 	}
+	c.usage.add(docType, &usage, err != nil)
+	return result, err
+}
 
-	for _, partName := range doc.ImageParts {
-		part, ok := fileParts[partName]
-		if !ok {
-			// This allows for optional parts, like the back of a driver's license
-			continue
-		}
+// extractDocument does the work of ExtractDocument. It also returns the document type,
+// once classified for DocumentTypeAuto, so that failures are counted under it.
+func (c *Client) extractDocument(ctx context.Context, req ExtractRequest) (*ExtractionResult, string, error) {
+	docType, fileParts, opts := req.DocumentType, req.FileParts, req.Options
 
-		mimeType := cleanMimeType(part.MimeType)
-		if !supportedMimeTypes[mimeType] {
-			detectedMimeType := http.DetectContentType(part.Content)
-			if !supportedMimeTypes[detectedMimeType] {
-				return nil, fmt.Errorf("%w: %s", ErrUnsupportedMimeType, mimeType)
-			}
-			mimeType = detectedMimeType
-		}
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
 
-		processedContent, err := c.preprocessContent(part.Content, mimeType, preprocess)
+	var classification *Classification
+	if docType == DocumentTypeAuto {
+		var err error
+		classification, err = c.classify(ctx, fileParts, opts)
 		if err != nil {
-			log.Printf("could not preprocess image part %s: %v, using original", partName, err)
-			processedContent = part.Content
+			return nil, unknownDocumentType, err
 		}
+		docType = classification.DocumentType
+	}
 
-		prompt = append(prompt, genai.Text(fmt.Sprintf("\nFile part: %s", partName)))
-		prompt = append(prompt, genai.Blob{MIMEType: mimeType, Data: processedContent})
+	doc, ok := c.config.Documents[docType]
+	if !ok {
+		return nil, docType, fmt.Errorf("%w: %s", ErrUnsupportedDocumentType, docType)
 	}
 
-	resp, err := c.generativeModel.GenerateContent(ctx, prompt...)
+	fileContents, err := c.buildFileParts(fileParts, doc.ImageParts, opts.Preprocess)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate content: %w", err)
+		return nil, docType, err
 	}
-
-	if len(resp.Candidates) == 0 || len(resp.Candidates[0].Content.Parts) == 0 {
-		return nil, fmt.Errorf("no content generated")
+	prompt := append([]Part{TextPart(doc.Prompt)}, fileContents...)
+
+	// result is the last successful extraction, kept when a model escalated to fails.
+	var result *ExtractionResult
+	policy := c.escalationPolicy(doc)
+	chain := c.modelChain(doc, opts)
+	for i, model := range chain {
+		last := i == len(chain)-1
+		r, err := c.extractWithModel(ctx, docType, doc, prompt, model, opts)
+		if err != nil {
+			if last || !policy.OnError || ctx.Err() != nil {
+				if result == nil {
+					return nil, docType, err
+				}
+				log.Printf("extraction with model %s failed, keeping the result of %s: %v", model, result.Model, err)
+				break
+			}
+			log.Printf("extraction with model %s failed, escalating to %s: %v", model, chain[i+1], err)
+			continue
+		}
+		result = r
+		if last || !policy.shouldEscalate(result) {
+			break
+		}
+		log.Printf("result of model %s did not meet the escalation policy, escalating to %s", model, chain[i+1])
 	}
 
-	jsonText, ok := resp.Candidates[0].Content.Parts[0].(genai.Text)
-	if !ok {
-		return nil, fmt.Errorf("unexpected response format from API")
+	// Apply masking if requested. The card number is always masked for configurations
+	// that predate the sensitive attribute. The raw response holds the values in clear
+	// text, so it is dropped.
+	if opts.Masking {
+		result.RawResponse = ""
+		data := result.ExtractedData
+		for name, field := range data {
+			if !doc.JSONStructure[name].Sensitive && name != "card_number" {
+				continue
+			}
+			if valueStr, ok := field.Value.(string); ok {
+				field.Value = maskString(valueStr)
+			}
+			if field.Normalized != "" {
+				field.Normalized = maskString(field.Normalized)
+			}
+			data[name] = field
+		}
 	}
 
-	cleaned := sanitizeJSONResponse(string(jsonText))
-	var data map[string]Field
-	if err := json.Unmarshal([]byte(cleaned), &data); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal JSON from response: %w (raw response: %s)", err, cleaned)
+	result.DocumentType = docType
+	result.Classification = classification
+	result.Verdict = verdict(result)
+	result.Status = c.documentStatus(result.ExtractedData)
+	if c.legacyValidation {
+		stripValidationDetails(result)
 	}
+	return result, docType, nil
+}
 
-	// Perform validation
-	for key, field := range data {
-		valueStr, ok := field.Value.(string)
-		if !ok {
-			continue // or handle non-string values if necessary
-		}
+// extractWithModel runs the extraction prompt against a single model and validates the
+// fields of the response. An empty model name selects the default model of the backend.
+// With opts.Samples above one, the prompt is run that many times and the responses are
+// merged by majority vote.
+func (c *Client) extractWithModel(ctx context.Context, docType string, doc Document, prompt []Part, model string, opts ExtractOptions) (*ExtractionResult, error) {
+	samples := opts.Samples
+	if samples < 1 {
+		samples = 1
+	}
 
-		validated := false
-		var isValid bool
-
-		switch docType {
-		case "driver_license":
-			if key == "card_number" {
-				isValid = validation.ValidateDriverLicenseNumber(valueStr)
-				validated = true
-			} else if strings.Contains(key, "date") {
-				isValid = validation.ValidateDate(valueStr)
-				validated = true
-			}
-		case "individual_number_card":
-			if key == "card_number" {
-				isValid = validation.ValidateMyNumber(valueStr)
-				validated = true
-			} else if strings.Contains(key, "date") {
-				isValid = validation.ValidateDate(valueStr)
-				validated = true
+	var (
+		outputs  []*modelOutput
+		warnings []*ForgeryWarning
+		lastErr  error
+	)
+	for i := 0; i < samples; i++ {
+		output, err := c.generateFields(ctx, doc, &ModelRequest{
+			Model:          model,
+			Parts:          prompt,
+			ResponseSchema: buildResponseSchema(doc),
+		}, opts.Fields)
+		if err == nil {
+			var warning *ForgeryWarning
+			if warning, err = splitForgeryWarning(output); err == nil {
+				outputs = append(outputs, output)
+				warnings = append(warnings, warning)
+				continue
 			}
 		}
+		if samples == 1 || ctx.Err() != nil {
+			return nil, err
+		}
+		log.Printf("sample %d of %d failed: %v", i+1, samples, err)
+		lastErr = err
+	}
+	if len(outputs) == 0 {
+		return nil, lastErr
+	}
 
-		if validated {
-			if isValid {
-				field.Validation = "valid"
-			} else {
-				field.Validation = "invalid"
-			}
-			data[key] = field
+	first := outputs[0]
+	data, forgeryWarning := first.data, warnings[0]
+	var attempts int
+	for _, output := range outputs {
+		attempts += output.attempts
+		normalizeFields(doc, output.data)
+	}
+	var consensus *Consensus
+	if samples > 1 {
+		sampleData := make([]map[string]Field, len(outputs))
+		for i, output := range outputs {
+			sampleData[i] = output.data
 		}
+		data, consensus = mergeSamples(doc, sampleData)
+		forgeryWarning = mergeForgeryWarnings(warnings)
 	}
 
-	// Extract forgery warning if present
+	c.validateFields(ctx, docType, doc, data)
+	applyFieldTypes(doc, data)
+	findings := c.checkRules(ctx, docType, doc, data)
+
+	// Keep only the requested fields
+	if len(opts.Fields) > 0 {
+		data = selectFields(data, opts.Fields)
+	}
+
+	if first.model != "" {
+		model = first.model
+	}
+	return &ExtractionResult{
+		Model:          model,
+		ExtractedData:  data,
+		ForgeryWarning: forgeryWarning,
+		Consensus:      consensus,
+		Findings:       findings,
+		RawResponse:    first.raw,
+		Attempts:       attempts,
+	}, nil
+}
+
+// splitForgeryWarning removes the forgery warning from the fields of output and returns it.
+func splitForgeryWarning(output *modelOutput) (*ForgeryWarning, error) {
 	var rawData map[string]json.RawMessage
-	if err := json.Unmarshal([]byte(cleaned), &rawData); err != nil {
+	if err := json.Unmarshal([]byte(output.raw), &rawData); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal raw JSON for forgery check: %w", err)
 	}
 
 	var forgeryWarning *ForgeryWarning
 	if fwRaw, ok := rawData["forgery_warning"]; ok {
 		if err := json.Unmarshal(fwRaw, &forgeryWarning); err == nil {
-			// Successfully unmarshalled, remove it from the main data map
-			delete(rawData, "forgery_warning")
-			// also remove from the `data` map which has the parsed fields
-			delete(data, "forgery_warning")
+			// Successfully unmarshalled, remove it from the parsed fields
+			delete(output.data, "forgery_warning")
 		} else {
 			log.Printf("could not unmarshal forgery_warning: %v", err)
 		}
 	}
+	return forgeryWarning, nil
+}
 
-	// Apply masking if requested
-	if masking {
-		if cardNumberField, ok := data["card_number"]; ok {
-			if valueStr, ok := cardNumberField.Value.(string); ok {
-				cardNumberField.Value = maskString(valueStr)
-				data["card_number"] = cardNumberField
-			}
+// mergeForgeryWarnings returns the first warning reporting signs of forgery, or the first
+// warning when no sample reports any.
+func mergeForgeryWarnings(warnings []*ForgeryWarning) *ForgeryWarning {
+	var first *ForgeryWarning
+	for _, w := range warnings {
+		if w == nil {
+			continue
+		}
+		if w.HasSignsOfForgery {
+			return w
+		}
+		if first == nil {
+			first = w
 		}
 	}
+	return first
+}
 
-	result := &ExtractionResult{
-		ExtractedData:  data,
-		ForgeryWarning: forgeryWarning,
-		RawResponse:    cleaned,
+// buildFileParts returns the model parts for the listed file parts, labelled with their
+// names. Parts missing from fileParts are skipped, which allows for optional parts like
+// the back of a driver's license.
+func (c *Client) buildFileParts(fileParts map[string]FilePart, partNames []string, preprocess bool) ([]Part, error) {
+	var parts []Part
+	for _, partName := range partNames {
+		part, ok := fileParts[partName]
+		if !ok {
+			continue
+		}
+
+		mimeType := cleanMimeType(part.MimeType)
+		if !supportedMimeTypes[mimeType] {
+			detectedMimeType := http.DetectContentType(part.Content)
+			if !supportedMimeTypes[detectedMimeType] {
+				return nil, fmt.Errorf("%w: %s", ErrUnsupportedMimeType, mimeType)
+			}
+			mimeType = detectedMimeType
+		}
+
+		processedContent, err := c.preprocessContent(part.Content, mimeType, preprocess)
+		if err != nil {
+			log.Printf("could not preprocess image part %s: %v, using original", partName, err)
+			processedContent = part.Content
+		}
+
+		parts = append(parts, TextPart(fmt.Sprintf("\nFile part: %s", partName)))
+		parts = append(parts, BlobPart(mimeType, processedContent))
 	}
+	return parts, nil
+}
 
-	return result, nil
+// selectFields returns the subset of data whose keys are listed in fields.
+func selectFields(data map[string]Field, fields []string) map[string]Field {
+	selected := make(map[string]Field, len(fields))
+	for _, name := range fields {
+		if field, ok := data[name]; ok {
+			selected[name] = field
+		}
+	}
+	return selected
 }
 
 func (c *Client) preprocessContent(content []byte, mimeType string, preprocess bool) ([]byte, error) {
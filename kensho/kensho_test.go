@@ -3,24 +3,36 @@ package kensho
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"image"
 	"image/png"
+	"math"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"reflect"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/google/generative-ai-go/genai"
+	"github.com/y-mitsuyoshi/kensho/kensho/validation"
+	"google.golang.org/api/googleapi"
+	"gopkg.in/yaml.v3"
 )
 
 // mockGenerativeModel is a mock implementation of the GenerativeModel interface.
 type mockGenerativeModel struct {
-	GenerateContentFunc func(ctx context.Context, parts ...genai.Part) (*genai.GenerateContentResponse, error)
+	GenerateContentFunc func(ctx context.Context, req *ModelRequest) (*ModelResponse, error)
 }
 
-func (m *mockGenerativeModel) GenerateContent(ctx context.Context, parts ...genai.Part) (*genai.GenerateContentResponse, error) {
+func (m *mockGenerativeModel) GenerateContent(ctx context.Context, req *ModelRequest) (*ModelResponse, error) {
 	if m.GenerateContentFunc != nil {
-		return m.GenerateContentFunc(ctx, parts...)
+		return m.GenerateContentFunc(ctx, req)
 	}
 	return nil, errors.New("GenerateContentFunc not implemented")
 }
@@ -33,6 +45,34 @@ func TestNewClient(t *testing.T) {
 		}
 	})
 
+	t.Run("should create new client with the OpenAI-compatible provider without api key", func(t *testing.T) {
+		client, err := NewClient(context.Background(), "", "local-vision-model", WithProvider(ProviderOpenAI), WithBaseURL("http://localhost:8000/v1"))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, ok := client.generativeModel.(*openAIModel); !ok {
+			t.Errorf("expected OpenAI-compatible backend, but got %T", client.generativeModel)
+		}
+		client.Close()
+	})
+
+	t.Run("should return error for OpenAI-compatible provider without model name", func(t *testing.T) {
+		if _, err := NewClient(context.Background(), "", "", WithProvider(ProviderOpenAI)); err == nil {
+			t.Error("expected error, but got nil")
+		}
+	})
+
+	t.Run("should use the given generative model", func(t *testing.T) {
+		model := &mockGenerativeModel{}
+		client, err := NewClient(context.Background(), "", "", WithGenerativeModel(model))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if client.generativeModel != model {
+			t.Error("expected the given generative model to be used")
+		}
+	})
+
 	t.Run("should create new client successfully with embedded config", func(t *testing.T) {
 		// This test depends on the real genai.NewClient, but since we can't easily mock it
 		// without a complex interface, we'll just ensure no error is returned.
@@ -83,6 +123,21 @@ documents:
 	})
 }
 
+func TestLoadDefaultConfig(t *testing.T) {
+	config, err := loadDefaultConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for docType, doc := range config.Documents {
+		if doc.Description == "" {
+			t.Errorf("%s: expected a description", docType)
+		}
+		if doc.Prompt == "" || len(doc.JSONStructure) == 0 || len(doc.ImageParts) == 0 {
+			t.Errorf("%s: expected prompt, json_structure and image_parts", docType)
+		}
+	}
+}
+
 func TestPreprocessImage(t *testing.T) {
 	// Create a simple 10x10 black PNG image for testing
 	img := image.NewGray(image.Rect(0, 0, 10, 10))
@@ -132,10 +187,10 @@ func TestExtract(t *testing.T) {
 		Documents: map[string]Document{
 			"test_doc": {
 				Prompt: "Extract data from this document.",
-				JSONStructure: map[string]string{
-					"name":        "name of the person",
-					"age":         "age of the person",
-					"card_number": "card number",
+				JSONStructure: map[string]FieldSpec{
+					"name":        {Label: "name of the person"},
+					"age":         {Label: "age of the person"},
+					"card_number": {Label: "card number"},
 				},
 				ImageParts: []string{"front"},
 			},
@@ -151,16 +206,8 @@ func TestExtract(t *testing.T) {
 
 	t.Run("should extract data successfully", func(t *testing.T) {
 		mockResponse := `{"name":{"value":"John Doe","confidence_score":0.9},"age":{"value":30,"confidence_score":0.95}}`
-		mockModel.GenerateContentFunc = func(ctx context.Context, parts ...genai.Part) (*genai.GenerateContentResponse, error) {
-			return &genai.GenerateContentResponse{
-				Candidates: []*genai.Candidate{
-					{
-						Content: &genai.Content{
-							Parts: []genai.Part{genai.Text(mockResponse)},
-						},
-					},
-				},
-			}, nil
+		mockModel.GenerateContentFunc = func(ctx context.Context, req *ModelRequest) (*ModelResponse, error) {
+			return &ModelResponse{Text: mockResponse}, nil
 		}
 
 		result, err := client.Extract(context.Background(), mockFileParts, "test_doc", false, false)
@@ -178,16 +225,8 @@ func TestExtract(t *testing.T) {
 	})
 
 	t.Run("should return error for invalid JSON response", func(t *testing.T) {
-		mockModel.GenerateContentFunc = func(ctx context.Context, parts ...genai.Part) (*genai.GenerateContentResponse, error) {
-			return &genai.GenerateContentResponse{
-				Candidates: []*genai.Candidate{
-					{
-						Content: &genai.Content{
-							Parts: []genai.Part{genai.Text("not a valid json")},
-						},
-					},
-				},
-			}, nil
+		mockModel.GenerateContentFunc = func(ctx context.Context, req *ModelRequest) (*ModelResponse, error) {
+			return &ModelResponse{Text: "not a valid json"}, nil
 		}
 
 		_, err := client.Extract(context.Background(), mockFileParts, "test_doc", false, false)
@@ -198,10 +237,8 @@ func TestExtract(t *testing.T) {
 
 	t.Run("should mask card number when masking is true", func(t *testing.T) {
 		mockResponse := `{"card_number":{"value":"123456789012","confidence_score":0.99}}`
-		mockModel.GenerateContentFunc = func(ctx context.Context, parts ...genai.Part) (*genai.GenerateContentResponse, error) {
-			return &genai.GenerateContentResponse{
-				Candidates: []*genai.Candidate{{Content: &genai.Content{Parts: []genai.Part{genai.Text(mockResponse)}}}},
-			}, nil
+		mockModel.GenerateContentFunc = func(ctx context.Context, req *ModelRequest) (*ModelResponse, error) {
+			return &ModelResponse{Text: mockResponse}, nil
 		}
 
 		result, err := client.Extract(context.Background(), mockFileParts, "test_doc", true, false)
@@ -214,14 +251,15 @@ func TestExtract(t *testing.T) {
 		if maskedValue != expectedMaskedValue {
 			t.Errorf("expected card number to be masked as %s, but got %s", expectedMaskedValue, maskedValue)
 		}
+		if result.RawResponse != "" {
+			t.Errorf("expected no raw response, but got %s", result.RawResponse)
+		}
 	})
 
 	t.Run("should parse forgery warning correctly", func(t *testing.T) {
 		mockResponse := `{"name":{"value":"John Doe","confidence_score":0.9}, "forgery_warning": {"has_signs_of_forgery": true, "reason": "font mismatch"}}`
-		mockModel.GenerateContentFunc = func(ctx context.Context, parts ...genai.Part) (*genai.GenerateContentResponse, error) {
-			return &genai.GenerateContentResponse{
-				Candidates: []*genai.Candidate{{Content: &genai.Content{Parts: []genai.Part{genai.Text(mockResponse)}}}},
-			}, nil
+		mockModel.GenerateContentFunc = func(ctx context.Context, req *ModelRequest) (*ModelResponse, error) {
+			return &ModelResponse{Text: mockResponse}, nil
 		}
 
 		result, err := client.Extract(context.Background(), mockFileParts, "test_doc", false, false)
@@ -247,10 +285,8 @@ func TestExtract(t *testing.T) {
 		pdfParts := map[string]FilePart{
 			"front": {Content: []byte("fake pdf data"), MimeType: "application/pdf"},
 		}
-		mockModel.GenerateContentFunc = func(ctx context.Context, parts ...genai.Part) (*genai.GenerateContentResponse, error) {
-			return &genai.GenerateContentResponse{
-				Candidates: []*genai.Candidate{{Content: &genai.Content{Parts: []genai.Part{genai.Text("{}")}}}},
-			}, nil
+		mockModel.GenerateContentFunc = func(ctx context.Context, req *ModelRequest) (*ModelResponse, error) {
+			return &ModelResponse{Text: "{}"}, nil
 		}
 
 		_, err := client.Extract(context.Background(), pdfParts, "test_doc", false, false)
@@ -276,8 +312,49 @@ func TestExtract(t *testing.T) {
 		}
 	})
 
+	t.Run("should keep only requested fields", func(t *testing.T) {
+		mockResponse := `{"name":{"value":"John Doe","confidence_score":0.9},"age":{"value":30,"confidence_score":0.95}}`
+		mockModel.GenerateContentFunc = func(ctx context.Context, req *ModelRequest) (*ModelResponse, error) {
+			return &ModelResponse{Text: mockResponse}, nil
+		}
+
+		result, err := client.ExtractDocument(context.Background(), ExtractRequest{
+			DocumentType: "test_doc",
+			FileParts:    mockFileParts,
+			Options:      ExtractOptions{Fields: []string{"name"}},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		expectedData := map[string]Field{
+			"name": {Value: "John Doe", ConfidenceScore: 0.9},
+		}
+		if !reflect.DeepEqual(result.ExtractedData, expectedData) {
+			t.Errorf("expected result %v, but got %v", expectedData, result.ExtractedData)
+		}
+	})
+
+	t.Run("should apply timeout to the model call", func(t *testing.T) {
+		mockModel.GenerateContentFunc = func(ctx context.Context, req *ModelRequest) (*ModelResponse, error) {
+			if _, ok := ctx.Deadline(); !ok {
+				t.Error("expected context to have a deadline")
+			}
+			return &ModelResponse{Text: "{}"}, nil
+		}
+
+		_, err := client.ExtractDocument(context.Background(), ExtractRequest{
+			DocumentType: "test_doc",
+			FileParts:    mockFileParts,
+			Options:      ExtractOptions{Timeout: time.Minute},
+		})
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+
 	t.Run("should return error when api returns error", func(t *testing.T) {
-		mockModel.GenerateContentFunc = func(ctx context.Context, parts ...genai.Part) (*genai.GenerateContentResponse, error) {
+		mockModel.GenerateContentFunc = func(ctx context.Context, req *ModelRequest) (*ModelResponse, error) {
 			return nil, errors.New("api error")
 		}
 
@@ -286,38 +363,1766 @@ func TestExtract(t *testing.T) {
 			t.Error("expected error, but got nil")
 		}
 	})
+}
 
-	t.Run("should return error when api returns no candidates", func(t *testing.T) {
-		mockModel.GenerateContentFunc = func(ctx context.Context, parts ...genai.Part) (*genai.GenerateContentResponse, error) {
-			return &genai.GenerateContentResponse{
-				Candidates: []*genai.Candidate{},
-			}, nil
+func TestBuildResponseSchema(t *testing.T) {
+	t.Run("should describe every field and the forgery warning", func(t *testing.T) {
+		schema := buildResponseSchema(Document{
+			JSONStructure: map[string]FieldSpec{
+				"name":        {Label: "氏名"},
+				"card_number": {Label: "免許の番号"},
+			},
+		})
+		if schema == nil {
+			t.Fatal("expected schema, but got nil")
 		}
 
-		_, err := client.Extract(context.Background(), mockFileParts, "test_doc", false, false)
-		if err == nil {
-			t.Error("expected error, but got nil")
+		expectedRequired := []string{"card_number", "name", "forgery_warning"}
+		if !reflect.DeepEqual(schema.Required, expectedRequired) {
+			t.Errorf("expected required %v, but got %v", expectedRequired, schema.Required)
+		}
+
+		name := schema.Properties["name"]
+		if name == nil || name.Description != "氏名" {
+			t.Fatalf("unexpected name schema: %+v", name)
+		}
+		if value := name.Properties["value"]; value.Type != SchemaTypeString || !value.Nullable {
+			t.Errorf("unexpected value schema: %+v", value)
+		}
+		if score := name.Properties["confidence_score"]; score.Type != SchemaTypeNumber {
+			t.Errorf("unexpected confidence_score schema: %+v", score)
+		}
+		if fw := schema.Properties["forgery_warning"]; fw == nil || fw.Properties["has_signs_of_forgery"].Type != SchemaTypeBoolean {
+			t.Errorf("unexpected forgery_warning schema: %+v", fw)
 		}
 	})
 
-	t.Run("should return error when api returns unexpected format", func(t *testing.T) {
-		mockModel.GenerateContentFunc = func(ctx context.Context, parts ...genai.Part) (*genai.GenerateContentResponse, error) {
-			return &genai.GenerateContentResponse{
-				Candidates: []*genai.Candidate{
-					{
-						Content: &genai.Content{
-							Parts: []genai.Part{
-								genai.ImageData("png", []byte("fake image data")),
-							},
-						},
-					},
-				},
-			}, nil
+	t.Run("should return nil without json structure", func(t *testing.T) {
+		if schema := buildResponseSchema(Document{}); schema != nil {
+			t.Errorf("expected nil schema, but got %+v", schema)
+		}
+	})
+
+	t.Run("should convert to a genai schema", func(t *testing.T) {
+		schema := toGenaiSchema(buildResponseSchema(Document{JSONStructure: map[string]FieldSpec{"name": {Label: "氏名"}}}))
+		if schema.Type != genai.TypeObject {
+			t.Errorf("expected object type, but got %v", schema.Type)
 		}
+		value := schema.Properties["name"].Properties["value"]
+		if value.Type != genai.TypeString || !value.Nullable {
+			t.Errorf("unexpected value schema: %+v", value)
+		}
+	})
 
-		_, err := client.Extract(context.Background(), mockFileParts, "test_doc", false, false)
-		if err == nil {
+	t.Run("should be sent with the extraction request", func(t *testing.T) {
+		var got *ModelRequest
+		client := &Client{
+			generativeModel: &mockGenerativeModel{GenerateContentFunc: func(ctx context.Context, req *ModelRequest) (*ModelResponse, error) {
+				got = req
+				return &ModelResponse{Text: "{}"}, nil
+			}},
+			config: &Config{Documents: map[string]Document{
+				"test_doc": {Prompt: "prompt", JSONStructure: map[string]FieldSpec{"name": {Label: "氏名"}}, ImageParts: []string{"front"}},
+			}},
+		}
+		_, err := client.ExtractDocument(context.Background(), ExtractRequest{
+			DocumentType: "test_doc",
+			FileParts:    map[string]FilePart{"front": {Content: []byte("fake image data"), MimeType: "image/png"}},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got.ResponseSchema == nil || got.ResponseSchema.Properties["name"] == nil {
+			t.Errorf("expected response schema for test_doc, but got %+v", got.ResponseSchema)
+		}
+	})
+}
+
+func TestClassify(t *testing.T) {
+	mockModel := &mockGenerativeModel{}
+	client := &Client{
+		generativeModel: mockModel,
+		config: &Config{
+			Documents: map[string]Document{
+				"driver_license":         {Description: "運転免許証", Prompt: "Extract the driver license.", JSONStructure: map[string]FieldSpec{"name": {Label: "氏名"}}, ImageParts: []string{"front", "back"}},
+				"individual_number_card": {Description: "マイナンバーカード", Prompt: "Extract the my number card.", ImageParts: []string{"front"}},
+			},
+		},
+	}
+	mockFileParts := map[string]FilePart{
+		"front": {Content: []byte("fake image data"), MimeType: "image/png"},
+	}
+
+	t.Run("should return the detected document type", func(t *testing.T) {
+		var got *ModelRequest
+		mockModel.GenerateContentFunc = func(ctx context.Context, req *ModelRequest) (*ModelResponse, error) {
+			got = req
+			return &ModelResponse{Text: `{"document_type":"individual_number_card","confidence_score":0.93}`}, nil
+		}
+
+		classification, err := client.Classify(context.Background(), mockFileParts)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		expected := &Classification{DocumentType: "individual_number_card", ConfidenceScore: 0.93}
+		if !reflect.DeepEqual(classification, expected) {
+			t.Errorf("expected %+v, but got %+v", expected, classification)
+		}
+
+		enum := got.ResponseSchema.Properties["document_type"].Enum
+		expectedEnum := []string{"driver_license", "individual_number_card", "unknown"}
+		if !reflect.DeepEqual(enum, expectedEnum) {
+			t.Errorf("expected enum %v, but got %v", expectedEnum, enum)
+		}
+	})
+
+	t.Run("should return error for an unknown document", func(t *testing.T) {
+		mockModel.GenerateContentFunc = func(ctx context.Context, req *ModelRequest) (*ModelResponse, error) {
+			return &ModelResponse{Text: `{"document_type":"unknown","confidence_score":0.4}`}, nil
+		}
+
+		_, err := client.Classify(context.Background(), mockFileParts)
+		if !errors.Is(err, ErrUnsupportedDocumentType) {
+			t.Errorf("expected error %v, but got %v", ErrUnsupportedDocumentType, err)
+		}
+	})
+
+	t.Run("should classify before extracting in auto mode", func(t *testing.T) {
+		mockModel.GenerateContentFunc = func(ctx context.Context, req *ModelRequest) (*ModelResponse, error) {
+			if req.Parts[0].Text == "Extract the driver license." {
+				return &ModelResponse{Text: `{"name":{"value":"見本太郎","confidence_score":0.95}}`}, nil
+			}
+			return &ModelResponse{Text: `{"document_type":"driver_license","confidence_score":0.88}`}, nil
+		}
+
+		result, err := client.ExtractDocument(context.Background(), ExtractRequest{
+			DocumentType: DocumentTypeAuto,
+			FileParts:    mockFileParts,
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result.DocumentType != "driver_license" {
+			t.Errorf("expected document type driver_license, but got %s", result.DocumentType)
+		}
+		if result.Classification == nil || result.Classification.ConfidenceScore != 0.88 {
+			t.Errorf("unexpected classification: %+v", result.Classification)
+		}
+		if result.ExtractedData["name"].Value != "見本太郎" {
+			t.Errorf("unexpected extracted data: %+v", result.ExtractedData)
+		}
+	})
+}
+
+func TestGenerateRetry(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond, Multiplier: 2, Jitter: 0.2}
+	request := &ModelRequest{Parts: []Part{TextPart("prompt")}}
+
+	t.Run("should retry transient errors until success", func(t *testing.T) {
+		calls := 0
+		client := &Client{retryPolicy: policy, generativeModel: &mockGenerativeModel{
+			GenerateContentFunc: func(ctx context.Context, req *ModelRequest) (*ModelResponse, error) {
+				calls++
+				switch calls {
+				case 1:
+					return nil, fmt.Errorf("%w: quota exceeded", ErrRateLimited)
+				case 2:
+					return nil, fmt.Errorf("%w: overloaded", ErrModelUnavailable)
+				}
+				return &ModelResponse{Text: "{}"}, nil
+			},
+		}}
+
+		if _, err := client.generate(context.Background(), request); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if calls != 3 {
+			t.Errorf("expected 3 calls, but got %d", calls)
+		}
+	})
+
+	t.Run("should give up after max attempts", func(t *testing.T) {
+		calls := 0
+		client := &Client{retryPolicy: policy, generativeModel: &mockGenerativeModel{
+			GenerateContentFunc: func(ctx context.Context, req *ModelRequest) (*ModelResponse, error) {
+				calls++
+				return nil, fmt.Errorf("%w: quota exceeded", ErrRateLimited)
+			},
+		}}
+
+		_, err := client.generate(context.Background(), request)
+		if !errors.Is(err, ErrRateLimited) {
+			t.Errorf("expected error %v, but got %v", ErrRateLimited, err)
+		}
+		if calls != 3 {
+			t.Errorf("expected 3 calls, but got %d", calls)
+		}
+	})
+
+	t.Run("should not retry permanent errors", func(t *testing.T) {
+		calls := 0
+		client := &Client{retryPolicy: policy, generativeModel: &mockGenerativeModel{
+			GenerateContentFunc: func(ctx context.Context, req *ModelRequest) (*ModelResponse, error) {
+				calls++
+				return nil, fmt.Errorf("%w: harmful content", ErrSafetyBlocked)
+			},
+		}}
+
+		_, err := client.generate(context.Background(), request)
+		if !errors.Is(err, ErrSafetyBlocked) {
+			t.Errorf("expected error %v, but got %v", ErrSafetyBlocked, err)
+		}
+		if calls != 1 {
+			t.Errorf("expected 1 call, but got %d", calls)
+		}
+	})
+
+	t.Run("should not wait beyond the context deadline", func(t *testing.T) {
+		calls := 0
+		slow := RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Hour}
+		client := &Client{retryPolicy: slow, generativeModel: &mockGenerativeModel{
+			GenerateContentFunc: func(ctx context.Context, req *ModelRequest) (*ModelResponse, error) {
+				calls++
+				return nil, fmt.Errorf("%w: overloaded", ErrModelUnavailable)
+			},
+		}}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_, err := client.generate(ctx, request)
+		if !errors.Is(err, ErrModelUnavailable) {
+			t.Errorf("expected error %v, but got %v", ErrModelUnavailable, err)
+		}
+		if calls != 1 {
+			t.Errorf("expected 1 call, but got %d", calls)
+		}
+	})
+
+	t.Run("should keep the last error when the context is canceled during backoff", func(t *testing.T) {
+		slow := RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Hour}
+		ctx, cancel := context.WithCancel(context.Background())
+		client := &Client{retryPolicy: slow, generativeModel: &mockGenerativeModel{
+			GenerateContentFunc: func(ctx context.Context, req *ModelRequest) (*ModelResponse, error) {
+				time.AfterFunc(10*time.Millisecond, cancel)
+				return nil, fmt.Errorf("%w: quota exceeded", ErrRateLimited)
+			},
+		}}
+
+		_, err := client.generate(ctx, request)
+		if !errors.Is(err, context.Canceled) || !errors.Is(err, ErrRateLimited) {
+			t.Errorf("expected both %v and %v, but got %v", context.Canceled, ErrRateLimited, err)
+		}
+	})
+
+	t.Run("should keep the sentinel error through Extract", func(t *testing.T) {
+		client := &Client{
+			generativeModel: &mockGenerativeModel{GenerateContentFunc: func(ctx context.Context, req *ModelRequest) (*ModelResponse, error) {
+				return nil, fmt.Errorf("%w: quota exceeded", ErrRateLimited)
+			}},
+			config: &Config{Documents: map[string]Document{"test_doc": {Prompt: "prompt", ImageParts: []string{"front"}}}},
+		}
+		_, err := client.Extract(context.Background(), map[string]FilePart{"front": {Content: []byte("fake image data"), MimeType: "image/png"}}, "test_doc", false, false)
+		if !errors.Is(err, ErrRateLimited) {
+			t.Errorf("expected error %v, but got %v", ErrRateLimited, err)
+		}
+	})
+}
+
+func TestClassifyGeminiError(t *testing.T) {
+	testCases := []struct {
+		name     string
+		err      error
+		expected error
+	}{
+		{"rate limited", &googleapi.Error{Code: http.StatusTooManyRequests}, ErrRateLimited},
+		{"unavailable", &googleapi.Error{Code: http.StatusServiceUnavailable}, ErrModelUnavailable},
+		{"blocked", &genai.BlockedError{Candidate: &genai.Candidate{FinishReason: genai.FinishReasonSafety}}, ErrSafetyBlocked},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := classifyGeminiError(tc.err); !errors.Is(err, tc.expected) {
+				t.Errorf("expected error %v, but got %v", tc.expected, err)
+			}
+		})
+	}
+
+	t.Run("should leave other errors untouched", func(t *testing.T) {
+		err := &googleapi.Error{Code: http.StatusBadRequest}
+		if got := classifyGeminiError(err); got != error(err) {
+			t.Errorf("expected original error, but got %v", got)
+		}
+	})
+}
+
+func TestRepair(t *testing.T) {
+	config := &Config{
+		Documents: map[string]Document{
+			"test_doc": {
+				Prompt:        "Extract data from this document.",
+				JSONStructure: map[string]FieldSpec{"name": {Label: "氏名"}, "card_number": {Label: "番号"}},
+				ImageParts:    []string{"front"},
+			},
+		},
+	}
+	request := ExtractRequest{
+		DocumentType: "test_doc",
+		FileParts:    map[string]FilePart{"front": {Content: []byte("fake image data"), MimeType: "image/png"}},
+	}
+
+	t.Run("should re-ask the model when the response is not valid JSON", func(t *testing.T) {
+		var prompts []*ModelRequest
+		client := &Client{config: config, repairAttempts: 2, generativeModel: &mockGenerativeModel{
+			GenerateContentFunc: func(ctx context.Context, req *ModelRequest) (*ModelResponse, error) {
+				prompts = append(prompts, req)
+				if len(prompts) == 1 {
+					return &ModelResponse{Text: `{"name": {"value": "見本太郎"`}, nil
+				}
+				return &ModelResponse{Text: `{"name":{"value":"見本太郎","confidence_score":0.9},"card_number":{"value":"123","confidence_score":0.8}}`}, nil
+			},
+		}}
+
+		result, err := client.ExtractDocument(context.Background(), request)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result.Attempts != 2 {
+			t.Errorf("expected 2 attempts, but got %d", result.Attempts)
+		}
+		last := prompts[1].Parts[len(prompts[1].Parts)-1]
+		if !strings.Contains(last.Text, "could not be parsed as JSON") {
+			t.Errorf("expected a correction instruction, but got %q", last.Text)
+		}
+		if len(prompts[1].Parts) != len(prompts[0].Parts)+1 {
+			t.Errorf("expected the original prompt plus one instruction, but got %d parts", len(prompts[1].Parts))
+		}
+	})
+
+	t.Run("should repair trailing commas and single quotes locally", func(t *testing.T) {
+		calls := 0
+		client := &Client{config: config, repairAttempts: 2, generativeModel: &mockGenerativeModel{
+			GenerateContentFunc: func(ctx context.Context, req *ModelRequest) (*ModelResponse, error) {
+				calls++
+				return &ModelResponse{Text: `{'name': {'value': 'O"Neil O\'Brien', 'confidence_score': 0.9,}, "card_number": {"value": "123", "confidence_score": 0.8},}`}, nil
+			},
+		}}
+
+		result, err := client.ExtractDocument(context.Background(), request)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if calls != 1 || result.Attempts != 1 {
+			t.Errorf("expected a single call, but got %d calls and %d attempts", calls, result.Attempts)
+		}
+		if result.ExtractedData["name"].Value != `O"Neil O'Brien` {
+			t.Errorf("unexpected name: %v", result.ExtractedData["name"].Value)
+		}
+	})
+
+	t.Run("should re-prompt for missing fields", func(t *testing.T) {
+		var prompts []*ModelRequest
+		client := &Client{config: config, repairAttempts: 2, generativeModel: &mockGenerativeModel{
+			GenerateContentFunc: func(ctx context.Context, req *ModelRequest) (*ModelResponse, error) {
+				prompts = append(prompts, req)
+				if len(prompts) == 1 {
+					return &ModelResponse{Text: `{"name":{"value":"見本太郎","confidence_score":0.9}}`}, nil
+				}
+				return &ModelResponse{Text: `{"name":{"value":"見本太郎","confidence_score":0.9},"card_number":{"value":null,"confidence_score":0}}`}, nil
+			},
+		}}
+
+		result, err := client.ExtractDocument(context.Background(), request)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result.Attempts != 2 {
+			t.Errorf("expected 2 attempts, but got %d", result.Attempts)
+		}
+		last := prompts[1].Parts[len(prompts[1].Parts)-1]
+		if !strings.Contains(last.Text, "card_number") {
+			t.Errorf("expected the missing field in the instruction, but got %q", last.Text)
+		}
+	})
+
+	t.Run("should return the partial result when fields stay missing", func(t *testing.T) {
+		calls := 0
+		client := &Client{config: config, repairAttempts: 1, generativeModel: &mockGenerativeModel{
+			GenerateContentFunc: func(ctx context.Context, req *ModelRequest) (*ModelResponse, error) {
+				calls++
+				return &ModelResponse{Text: `{"name":{"value":"見本太郎","confidence_score":0.9}}`}, nil
+			},
+		}}
+
+		result, err := client.ExtractDocument(context.Background(), request)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if calls != 2 || result.Attempts != 2 {
+			t.Errorf("expected 2 calls, but got %d calls and %d attempts", calls, result.Attempts)
+		}
+		if _, ok := result.ExtractedData["name"]; !ok {
+			t.Error("expected the partial result to be returned")
+		}
+	})
+
+	t.Run("should return the partial result when a re-prompt fails", func(t *testing.T) {
+		calls := 0
+		client := &Client{config: config, repairAttempts: 2, generativeModel: &mockGenerativeModel{
+			GenerateContentFunc: func(ctx context.Context, req *ModelRequest) (*ModelResponse, error) {
+				calls++
+				if calls == 1 {
+					return &ModelResponse{Text: `{"name":{"value":"見本太郎","confidence_score":0.9}}`}, nil
+				}
+				return nil, errors.New("api error")
+			},
+		}}
+
+		result, err := client.ExtractDocument(context.Background(), request)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if calls != 2 || result.Attempts != 1 {
+			t.Errorf("expected 2 calls and 1 attempt, but got %d calls and %d attempts", calls, result.Attempts)
+		}
+		if result.ExtractedData["name"].Value != "見本太郎" {
+			t.Errorf("expected the partial result to be returned, but got %v", result.ExtractedData)
+		}
+		if _, ok := result.ExtractedData["card_number"]; ok {
+			t.Error("expected card_number to stay missing")
+		}
+	})
+
+	t.Run("should not re-prompt for fields outside the requested subset", func(t *testing.T) {
+		calls := 0
+		client := &Client{config: config, repairAttempts: 2, generativeModel: &mockGenerativeModel{
+			GenerateContentFunc: func(ctx context.Context, req *ModelRequest) (*ModelResponse, error) {
+				calls++
+				return &ModelResponse{Text: `{"name":{"value":"見本太郎","confidence_score":0.9}}`}, nil
+			},
+		}}
+
+		subset := request
+		subset.Options.Fields = []string{"name"}
+		if _, err := client.ExtractDocument(context.Background(), subset); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if calls != 1 {
+			t.Errorf("expected 1 call, but got %d", calls)
+		}
+	})
+
+	t.Run("should give up after the repair attempts", func(t *testing.T) {
+		calls := 0
+		client := &Client{config: config, repairAttempts: 2, generativeModel: &mockGenerativeModel{
+			GenerateContentFunc: func(ctx context.Context, req *ModelRequest) (*ModelResponse, error) {
+				calls++
+				return &ModelResponse{Text: "not a valid json"}, nil
+			},
+		}}
+
+		if _, err := client.ExtractDocument(context.Background(), request); err == nil {
 			t.Error("expected error, but got nil")
 		}
+		if calls != 3 {
+			t.Errorf("expected 3 calls, but got %d", calls)
+		}
+	})
+}
+
+func TestModelChain(t *testing.T) {
+	config := &Config{
+		Documents: map[string]Document{
+			"test_doc": {
+				Prompt:        "Extract data from this document.",
+				JSONStructure: map[string]FieldSpec{"name": {Label: "氏名"}},
+				ImageParts:    []string{"front"},
+			},
+			"pinned_doc": {
+				Prompt:        "Extract data from this document.",
+				JSONStructure: map[string]FieldSpec{"name": {Label: "氏名"}},
+				ImageParts:    []string{"front"},
+				Models:        []string{"custom-model"},
+			},
+		},
+	}
+	request := ExtractRequest{
+		DocumentType: "test_doc",
+		FileParts:    map[string]FilePart{"front": {Content: []byte("fake image data"), MimeType: "image/png"}},
+	}
+	newClient := func(respond func(model string) (*ModelResponse, error), calls *[]string) *Client {
+		return &Client{
+			config:     config,
+			models:     []string{"gemini-2.5-flash", "gemini-2.5-pro"},
+			escalation: DefaultEscalationPolicy,
+			generativeModel: &mockGenerativeModel{GenerateContentFunc: func(ctx context.Context, req *ModelRequest) (*ModelResponse, error) {
+				*calls = append(*calls, req.Model)
+				return respond(req.Model)
+			}},
+		}
+	}
+
+	t.Run("should keep the first model result when it is good enough", func(t *testing.T) {
+		var calls []string
+		client := newClient(func(model string) (*ModelResponse, error) {
+			return &ModelResponse{Text: `{"name":{"value":"見本太郎","confidence_score":0.95}}`, Model: model}, nil
+		}, &calls)
+
+		result, err := client.ExtractDocument(context.Background(), request)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(calls, []string{"gemini-2.5-flash"}) {
+			t.Errorf("unexpected calls: %v", calls)
+		}
+		if result.Model != "gemini-2.5-flash" {
+			t.Errorf("expected model gemini-2.5-flash, but got %s", result.Model)
+		}
+	})
+
+	t.Run("should escalate on low average confidence", func(t *testing.T) {
+		var calls []string
+		client := newClient(func(model string) (*ModelResponse, error) {
+			if model == "gemini-2.5-flash" {
+				return &ModelResponse{Text: `{"name":{"value":"見本太郎","confidence_score":0.4}}`}, nil
+			}
+			return &ModelResponse{Text: `{"name":{"value":"見本太郎","confidence_score":0.9}}`}, nil
+		}, &calls)
+
+		result, err := client.ExtractDocument(context.Background(), request)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(calls, []string{"gemini-2.5-flash", "gemini-2.5-pro"}) {
+			t.Errorf("unexpected calls: %v", calls)
+		}
+		if result.Model != "gemini-2.5-pro" || result.ExtractedData["name"].ConfidenceScore != 0.9 {
+			t.Errorf("expected the result of gemini-2.5-pro, but got %+v", result)
+		}
+	})
+
+	t.Run("should escalate on error", func(t *testing.T) {
+		var calls []string
+		client := newClient(func(model string) (*ModelResponse, error) {
+			if model == "gemini-2.5-flash" {
+				return nil, errors.New("api error")
+			}
+			return &ModelResponse{Text: `{"name":{"value":"見本太郎","confidence_score":0.9}}`}, nil
+		}, &calls)
+
+		result, err := client.ExtractDocument(context.Background(), request)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result.Model != "gemini-2.5-pro" {
+			t.Errorf("expected model gemini-2.5-pro, but got %s", result.Model)
+		}
+	})
+
+	t.Run("should keep the earlier result when the escalated model fails", func(t *testing.T) {
+		var calls []string
+		client := newClient(func(model string) (*ModelResponse, error) {
+			if model == "gemini-2.5-flash" {
+				return &ModelResponse{Text: `{"name":{"value":"見本太郎","confidence_score":0.4}}`, Model: model}, nil
+			}
+			return nil, errors.New("api error")
+		}, &calls)
+
+		result, err := client.ExtractDocument(context.Background(), request)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(calls, []string{"gemini-2.5-flash", "gemini-2.5-pro"}) {
+			t.Errorf("unexpected calls: %v", calls)
+		}
+		if result.Model != "gemini-2.5-flash" || result.ExtractedData["name"].ConfidenceScore != 0.4 {
+			t.Errorf("expected the result of gemini-2.5-flash, but got %+v", result)
+		}
+	})
+
+	t.Run("should escalate when a validated field is invalid", func(t *testing.T) {
+		policy := EscalationPolicy{ValidatedFields: []string{"card_number"}}
+		if !policy.shouldEscalate(&ExtractionResult{ExtractedData: map[string]Field{"card_number": {Validation: "invalid"}}}) {
+			t.Error("expected escalation for an invalid card_number")
+		}
+		if policy.shouldEscalate(&ExtractionResult{ExtractedData: map[string]Field{"card_number": {Validation: "valid"}}}) {
+			t.Error("expected no escalation for a valid card_number")
+		}
+	})
+
+	t.Run("should not escalate on blank optional fields", func(t *testing.T) {
+		data := map[string]Field{
+			"name":          {Value: "見本太郎", ConfidenceScore: 0.95},
+			"address":       {Value: "東京都千代田区霞が関2-1-1", ConfidenceScore: 0.9},
+			"former_name":   {Value: nil, ConfidenceScore: 0},
+			"remarks":       {Value: nil, ConfidenceScore: 0},
+			"mrz_line1":     {Value: "", ConfidenceScore: 0},
+			"front_address": {Value: nil, ConfidenceScore: 0},
+		}
+		if DefaultEscalationPolicy.shouldEscalate(&ExtractionResult{ExtractedData: data}) {
+			t.Errorf("expected no escalation, but got an average confidence of %v", averageConfidence(data))
+		}
+		if !DefaultEscalationPolicy.shouldEscalate(&ExtractionResult{ExtractedData: map[string]Field{"name": {Value: nil}}}) {
+			t.Error("expected escalation when no field has a value")
+		}
+	})
+
+	t.Run("should return the last result when every model falls short", func(t *testing.T) {
+		var calls []string
+		client := newClient(func(model string) (*ModelResponse, error) {
+			return &ModelResponse{Text: `{"name":{"value":"見本太郎","confidence_score":0.3}}`}, nil
+		}, &calls)
+
+		result, err := client.ExtractDocument(context.Background(), request)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(calls) != 2 || result.Model != "gemini-2.5-pro" {
+			t.Errorf("unexpected calls %v and model %s", calls, result.Model)
+		}
+	})
+
+	t.Run("should use the models of the document type", func(t *testing.T) {
+		var calls []string
+		client := newClient(func(model string) (*ModelResponse, error) {
+			return &ModelResponse{Text: `{"name":{"value":"見本太郎","confidence_score":0.3}}`}, nil
+		}, &calls)
+
+		pinned := request
+		pinned.DocumentType = "pinned_doc"
+		if _, err := client.ExtractDocument(context.Background(), pinned); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(calls, []string{"custom-model"}) {
+			t.Errorf("unexpected calls: %v", calls)
+		}
+	})
+
+	t.Run("should parse models and escalation from YAML", func(t *testing.T) {
+		var parsed Config
+		err := yaml.Unmarshal([]byte(`
+documents:
+  driver_license:
+    prompt: "prompt"
+    models: [gemini-2.5-flash, gemini-2.5-pro]
+    escalation:
+      min_average_confidence: 0.8
+      validated_fields: [card_number]
+      on_error: true
+`), &parsed)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		doc := parsed.Documents["driver_license"]
+		if !reflect.DeepEqual(doc.Models, []string{"gemini-2.5-flash", "gemini-2.5-pro"}) {
+			t.Errorf("unexpected models: %v", doc.Models)
+		}
+		expected := &EscalationPolicy{MinAverageConfidence: 0.8, ValidatedFields: []string{"card_number"}, OnError: true}
+		if !reflect.DeepEqual(doc.Escalation, expected) {
+			t.Errorf("expected escalation %+v, but got %+v", expected, doc.Escalation)
+		}
+	})
+
+	t.Run("should build the chain from the client options", func(t *testing.T) {
+		client, err := NewClientWithConfig(context.Background(), "", "gemini-2.5-flash", *config,
+			WithGenerativeModel(&mockGenerativeModel{}), WithFallbackModels("gemini-2.5-pro"))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(client.models, []string{"gemini-2.5-flash", "gemini-2.5-pro"}) {
+			t.Errorf("unexpected model chain: %v", client.models)
+		}
+	})
+}
+
+func TestUsage(t *testing.T) {
+	config := &Config{
+		Documents: map[string]Document{
+			"test_doc": {
+				Prompt:        "Extract data from this document.",
+				JSONStructure: map[string]FieldSpec{"name": {Label: "氏名"}},
+				ImageParts:    []string{"front"},
+			},
+		},
+	}
+	request := ExtractRequest{
+		DocumentType: "test_doc",
+		FileParts:    map[string]FilePart{"front": {Content: []byte("fake image data"), MimeType: "image/png"}},
+	}
+	prices := PriceTable{
+		"flash": {InputPerMillionTokens: 1, OutputPerMillionTokens: 2},
+		"pro":   {InputPerMillionTokens: 10, OutputPerMillionTokens: 20},
+	}
+
+	t.Run("should sum usage over escalated models", func(t *testing.T) {
+		client := &Client{
+			config:     config,
+			models:     []string{"flash", "pro"},
+			escalation: DefaultEscalationPolicy,
+			prices:     prices,
+			generativeModel: &mockGenerativeModel{GenerateContentFunc: func(ctx context.Context, req *ModelRequest) (*ModelResponse, error) {
+				score := 0.9
+				if req.Model == "flash" {
+					score = 0.4
+				}
+				return &ModelResponse{
+					Text:  fmt.Sprintf(`{"name":{"value":"見本太郎","confidence_score":%v}}`, score),
+					Model: req.Model,
+					Usage: TokenUsage{PromptTokens: 1000, CandidatesTokens: 100, TotalTokens: 1100},
+				}, nil
+			}},
+		}
+
+		result, err := client.ExtractDocument(context.Background(), request)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result.Usage == nil {
+			t.Fatal("expected usage, but got nil")
+		}
+		if result.Usage.Calls != 2 || result.Usage.TotalTokens != 2200 {
+			t.Errorf("expected 2 calls and 2200 tokens, but got %d calls and %d tokens", result.Usage.Calls, result.Usage.TotalTokens)
+		}
+		if result.Usage.Model != "pro" {
+			t.Errorf("expected model pro, but got %s", result.Usage.Model)
+		}
+		// flash: 1000*1 + 100*2, pro: 1000*10 + 100*20, per million tokens
+		if want := 0.0132; math.Abs(result.Usage.EstimatedCost-want) > 1e-9 {
+			t.Errorf("expected cost %v, but got %v", want, result.Usage.EstimatedCost)
+		}
+	})
+
+	t.Run("should aggregate usage per document type", func(t *testing.T) {
+		calls := 0
+		client := &Client{
+			config: config,
+			prices: prices,
+			generativeModel: &mockGenerativeModel{GenerateContentFunc: func(ctx context.Context, req *ModelRequest) (*ModelResponse, error) {
+				calls++
+				if calls == 2 {
+					return nil, ErrSafetyBlocked
+				}
+				return &ModelResponse{
+					Text:  `{"name":{"value":"見本太郎","confidence_score":0.9}}`,
+					Usage: TokenUsage{PromptTokens: 10, CandidatesTokens: 5, TotalTokens: 15},
+				}, nil
+			}},
+		}
+
+		for i := 0; i < 2; i++ {
+			client.ExtractDocument(context.Background(), request)
+		}
+
+		totals := client.Usage()["test_doc"]
+		if totals.Extractions != 2 || totals.Failures != 1 {
+			t.Errorf("expected 2 extractions and 1 failure, but got %d and %d", totals.Extractions, totals.Failures)
+		}
+		if totals.Calls != 1 || totals.TotalTokens != 15 {
+			t.Errorf("expected 1 call and 15 tokens, but got %d calls and %d tokens", totals.Calls, totals.TotalTokens)
+		}
+	})
+
+	t.Run("should count classifications under the classified document type", func(t *testing.T) {
+		classified := true
+		client := &Client{
+			config: config,
+			prices: prices,
+			generativeModel: &mockGenerativeModel{GenerateContentFunc: func(ctx context.Context, req *ModelRequest) (*ModelResponse, error) {
+				if _, ok := req.ResponseSchema.Properties["document_type"]; !ok {
+					return nil, ErrSafetyBlocked
+				}
+				if !classified {
+					return nil, ErrModelUnavailable
+				}
+				return &ModelResponse{
+					Text:  `{"document_type":"test_doc","confidence_score":0.9}`,
+					Usage: TokenUsage{PromptTokens: 10, CandidatesTokens: 5, TotalTokens: 15},
+				}, nil
+			}},
+		}
+
+		if _, err := client.ExtractDocument(context.Background(), ExtractRequest{DocumentType: DocumentTypeAuto, FileParts: request.FileParts}); err == nil {
+			t.Fatal("expected an error, but got nil")
+		}
+		if _, err := client.Classify(context.Background(), request.FileParts); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		classified = false
+		client.Classify(context.Background(), request.FileParts)
+
+		usage := client.Usage()
+		if _, ok := usage[DocumentTypeAuto]; ok {
+			t.Errorf("expected no usage under %s, but got %+v", DocumentTypeAuto, usage)
+		}
+		totals := usage["test_doc"]
+		if totals.Extractions != 1 || totals.Classifications != 1 || totals.Failures != 1 {
+			t.Errorf("expected 1 extraction, 1 classification and 1 failure, but got %+v", totals)
+		}
+		if totals.Calls != 2 || totals.TotalTokens != 30 {
+			t.Errorf("expected 2 calls and 30 tokens, but got %d calls and %d tokens", totals.Calls, totals.TotalTokens)
+		}
+		if totals := usage[unknownDocumentType]; totals.Classifications != 1 || totals.Failures != 1 {
+			t.Errorf("expected 1 failed classification, but got %+v", totals)
+		}
+	})
+
+	t.Run("should match the longest price prefix", func(t *testing.T) {
+		price, ok := DefaultPriceTable.lookup("gemini-2.5-flash-lite-001")
+		if !ok || price != DefaultPriceTable["gemini-2.5-flash-lite"] {
+			t.Errorf("unexpected price: %+v", price)
+		}
+		if _, ok := DefaultPriceTable.lookup("unknown-model"); ok {
+			t.Error("expected no price for unknown model")
+		}
+	})
+}
+
+func TestSamples(t *testing.T) {
+	config := &Config{
+		Documents: map[string]Document{
+			"test_doc": {
+				Prompt:        "Extract data from this document.",
+				JSONStructure: map[string]FieldSpec{"name": {Label: "氏名"}, "address": {Label: "住所"}, "birth_date": {Label: "生年月日", Type: FieldTypeDate}},
+				ImageParts:    []string{"front"},
+			},
+		},
+	}
+	responses := []string{
+		`{"name":{"value":"見本太郎","confidence_score":0.9},"address":{"value":"東京都千代田区","confidence_score":0.8},"birth_date":{"value":"平成30年2月1日","confidence_score":0.9},"forgery_warning":{"has_signs_of_forgery":false,"reason":""}}`,
+		`{"name":{"value":"見本太郎 ","confidence_score":0.7},"address":{"value":"東京都千代田区","confidence_score":0.8},"birth_date":{"value":"H30.2.1","confidence_score":0.9},"forgery_warning":{"has_signs_of_forgery":true,"reason":"edited photo"}}`,
+		`{"name":{"value":"見本大郎","confidence_score":0.9},"address":{"value":"東京都千代田区","confidence_score":0.8},"birth_date":{"value":"2018-02-01","confidence_score":0.9}}`,
+	}
+	calls := 0
+	client := &Client{
+		config: config,
+		generativeModel: &mockGenerativeModel{GenerateContentFunc: func(ctx context.Context, req *ModelRequest) (*ModelResponse, error) {
+			calls++
+			return &ModelResponse{Text: responses[(calls-1)%len(responses)]}, nil
+		}},
+	}
+
+	result, err := client.ExtractDocument(context.Background(), ExtractRequest{
+		DocumentType: "test_doc",
+		FileParts:    map[string]FilePart{"front": {Content: []byte("fake image data"), MimeType: "image/png"}},
+		Options:      ExtractOptions{Samples: 3},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("expected 3 calls, but got %d", calls)
+	}
+
+	t.Run("should keep the majority value", func(t *testing.T) {
+		name := result.ExtractedData["name"]
+		if name.Value != "見本太郎" {
+			t.Errorf("expected 見本太郎, but got %v", name.Value)
+		}
+		if math.Abs(name.Agreement-2.0/3) > 1e-9 {
+			t.Errorf("expected agreement 0.67, but got %v", name.Agreement)
+		}
+		if want := 0.8 * 2 / 3; math.Abs(name.ConfidenceScore-want) > 1e-9 {
+			t.Errorf("expected confidence %v, but got %v", want, name.ConfidenceScore)
+		}
+	})
+
+	t.Run("should flag disputed fields", func(t *testing.T) {
+		if result.Consensus == nil || result.Consensus.Samples != 3 {
+			t.Fatalf("unexpected consensus: %+v", result.Consensus)
+		}
+		if !reflect.DeepEqual(result.Consensus.DisputedFields, []string{"name"}) {
+			t.Errorf("expected disputed fields [name], but got %v", result.Consensus.DisputedFields)
+		}
+		if result.ExtractedData["address"].Agreement != 1 {
+			t.Errorf("expected full agreement on address, but got %v", result.ExtractedData["address"].Agreement)
+		}
+	})
+
+	t.Run("should agree on dates written in different notations", func(t *testing.T) {
+		birthDate := result.ExtractedData["birth_date"]
+		if birthDate.Agreement != 1 || birthDate.Normalized != "2018-02-01" {
+			t.Errorf("expected full agreement on 2018-02-01, but got %v and %q", birthDate.Agreement, birthDate.Normalized)
+		}
+	})
+
+	t.Run("should report forgery seen by any sample", func(t *testing.T) {
+		if result.ForgeryWarning == nil || !result.ForgeryWarning.HasSignsOfForgery {
+			t.Errorf("expected forgery warning, but got %+v", result.ForgeryWarning)
+		}
+		if _, ok := result.ExtractedData["forgery_warning"]; ok {
+			t.Error("expected forgery_warning to be removed from extracted data")
+		}
+	})
+}
+
+func TestExtractBatch(t *testing.T) {
+	config := &Config{
+		Documents: map[string]Document{
+			"test_doc": {
+				Prompt:        "Extract data from this document.",
+				JSONStructure: map[string]FieldSpec{"name": {Label: "氏名"}},
+				ImageParts:    []string{"front"},
+			},
+		},
+	}
+	newRequests := func(n int) []ExtractRequest {
+		reqs := make([]ExtractRequest, n)
+		for i := range reqs {
+			reqs[i] = ExtractRequest{
+				DocumentType: "test_doc",
+				FileParts:    map[string]FilePart{"front": {Content: []byte(fmt.Sprintf("image %d", i)), MimeType: "image/png"}},
+			}
+		}
+		return reqs
+	}
+	// respond echoes the image content so that results can be matched with their requests.
+	respond := func(req *ModelRequest) (*ModelResponse, error) {
+		content := string(req.Parts[len(req.Parts)-1].Data)
+		if content == "image 2" {
+			return nil, ErrSafetyBlocked
+		}
+		return &ModelResponse{Text: fmt.Sprintf(`{"name":{"value":%q,"confidence_score":0.9}}`, content)}, nil
+	}
+
+	t.Run("should return per-item results in order with bounded concurrency", func(t *testing.T) {
+		var inFlight, maxInFlight int32
+		client := &Client{
+			config: config,
+			generativeModel: &mockGenerativeModel{GenerateContentFunc: func(ctx context.Context, req *ModelRequest) (*ModelResponse, error) {
+				n := atomic.AddInt32(&inFlight, 1)
+				defer atomic.AddInt32(&inFlight, -1)
+				for {
+					max := atomic.LoadInt32(&maxInFlight)
+					if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+						break
+					}
+				}
+				time.Sleep(10 * time.Millisecond)
+				return respond(req)
+			}},
+		}
+
+		results, err := client.ExtractBatch(context.Background(), newRequests(8), BatchOptions{Concurrency: 3})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(results) != 8 {
+			t.Fatalf("expected 8 results, but got %d", len(results))
+		}
+		for i, r := range results {
+			if r.Index != i {
+				t.Errorf("expected index %d, but got %d", i, r.Index)
+			}
+			if i == 2 {
+				if !errors.Is(r.Err, ErrSafetyBlocked) {
+					t.Errorf("expected error %v, but got %v", ErrSafetyBlocked, r.Err)
+				}
+				continue
+			}
+			if r.Err != nil {
+				t.Errorf("unexpected error for item %d: %v", i, r.Err)
+			} else if want := fmt.Sprintf("image %d", i); r.Result.ExtractedData["name"].Value != want {
+				t.Errorf("expected %s, but got %v", want, r.Result.ExtractedData["name"].Value)
+			}
+		}
+		if maxInFlight > 3 {
+			t.Errorf("expected at most 3 concurrent calls, but got %d", maxInFlight)
+		}
+	})
+
+	t.Run("should limit the request rate", func(t *testing.T) {
+		client := &Client{
+			config: config,
+			generativeModel: &mockGenerativeModel{GenerateContentFunc: func(ctx context.Context, req *ModelRequest) (*ModelResponse, error) {
+				return respond(req)
+			}},
+		}
+
+		start := time.Now()
+		if _, err := client.ExtractBatch(context.Background(), newRequests(3), BatchOptions{Concurrency: 3, RequestsPerMinute: 1200}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+			t.Errorf("expected 3 requests at 1200 rpm to take at least 100ms, but took %v", elapsed)
+		}
+	})
+
+	t.Run("should stop dispatching on cancel and let in-flight items finish", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		client := &Client{
+			config: config,
+			generativeModel: &mockGenerativeModel{GenerateContentFunc: func(callCtx context.Context, req *ModelRequest) (*ModelResponse, error) {
+				cancel()
+				time.Sleep(10 * time.Millisecond)
+				if err := callCtx.Err(); err != nil {
+					return nil, err
+				}
+				return respond(req)
+			}},
+		}
+
+		results, err := client.ExtractBatch(ctx, newRequests(5), BatchOptions{Concurrency: 1})
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected error %v, but got %v", context.Canceled, err)
+		}
+		if results[0].Err != nil {
+			t.Errorf("expected in-flight item to finish, but got %v", results[0].Err)
+		}
+		for _, r := range results[1:] {
+			if !errors.Is(r.Err, context.Canceled) {
+				t.Errorf("expected item %d to be canceled, but got %v", r.Index, r.Err)
+			}
+		}
+	})
+
+	t.Run("should stop in-flight items at the deadline", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		client := &Client{
+			config: config,
+			generativeModel: &mockGenerativeModel{GenerateContentFunc: func(callCtx context.Context, req *ModelRequest) (*ModelResponse, error) {
+				select {
+				case <-callCtx.Done():
+					return nil, callCtx.Err()
+				case <-time.After(5 * time.Second):
+					return respond(req)
+				}
+			}},
+		}
+
+		start := time.Now()
+		results, err := client.ExtractBatch(ctx, newRequests(2), BatchOptions{Concurrency: 2})
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected error %v, but got %v", context.DeadlineExceeded, err)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("expected the batch to end at the deadline, but took %v", elapsed)
+		}
+		for _, r := range results {
+			if !errors.Is(r.Err, context.DeadlineExceeded) {
+				t.Errorf("expected item %d to exceed the deadline, but got %v", r.Index, r.Err)
+			}
+		}
+	})
+}
+
+func TestFieldSpec(t *testing.T) {
+	t.Run("should accept label-only and typed fields", func(t *testing.T) {
+		var doc Document
+		err := yaml.Unmarshal([]byte(`
+json_structure:
+  name: "氏名"
+  birth_date: { label: "生年月日", type: date }
+  remarks: { label: "備考", required: false }
+  color: { label: "色", type: enum, enum: [gold, blue, green] }
+  card_number: { label: "番号", format: "^[0-9]{12}$", sensitive: true }
+`), &doc)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		expected := map[string]FieldSpec{
+			"name":        {Label: "氏名"},
+			"birth_date":  {Label: "生年月日", Type: FieldTypeDate},
+			"remarks":     {Label: "備考", Optional: true},
+			"color":       {Label: "色", Type: FieldTypeEnum, Enum: []string{"gold", "blue", "green"}},
+			"card_number": {Label: "番号", Format: "^[0-9]{12}$", Sensitive: true},
+		}
+		if !reflect.DeepEqual(doc.JSONStructure, expected) {
+			t.Errorf("expected %+v, but got %+v", expected, doc.JSONStructure)
+		}
+	})
+
+	t.Run("should reject invalid field definitions", func(t *testing.T) {
+		for _, src := range []string{
+			`{ label: "色", type: color }`,
+			`{ label: "色", type: enum }`,
+			`{ label: "番号", format: "[" }`,
+		} {
+			var spec FieldSpec
+			if err := yaml.Unmarshal([]byte(src), &spec); err == nil {
+				t.Errorf("expected error for %s, but got nil", src)
+			}
+		}
+	})
+
+	t.Run("should coerce values to the field type", func(t *testing.T) {
+		testCases := []struct {
+			spec  FieldSpec
+			value interface{}
+			want  interface{}
+			valid bool
+		}{
+			{FieldSpec{Type: FieldTypeNumber}, "1,234", float64(1234), true},
+			{FieldSpec{Type: FieldTypeNumber}, "abc", "abc", false},
+			{FieldSpec{Type: FieldTypeBool}, "はい", true, true},
+			{FieldSpec{Type: FieldTypeEnum, Enum: []string{"gold", "blue"}}, " Gold", "gold", true},
+			{FieldSpec{Type: FieldTypeEnum, Enum: []string{"gold", "blue"}}, "red", "red", false},
+			{FieldSpec{Type: FieldTypeDate}, float64(20230115), float64(20230115), false},
+			{FieldSpec{Type: FieldTypeString}, float64(42), "42", true},
+			{FieldSpec{Type: FieldTypeNumber}, nil, nil, true},
+		}
+		for _, tc := range testCases {
+			got, err := coerceValue(tc.spec, tc.value)
+			if got != tc.want || (err == nil) != tc.valid {
+				t.Errorf("coerceValue(%+v, %v): expected %v (valid: %v), but got %v (error: %v)", tc.spec, tc.value, tc.want, tc.valid, got, err)
+			}
+		}
+	})
+
+	t.Run("should mark nonconforming values invalid and mask sensitive fields", func(t *testing.T) {
+		client := &Client{
+			config: &Config{Documents: map[string]Document{
+				"test_doc": {
+					Prompt: "Extract data from this document.",
+					JSONStructure: map[string]FieldSpec{
+						"height":    {Label: "身長", Type: FieldTypeNumber},
+						"member_id": {Label: "会員番号", Sensitive: true},
+					},
+					ImageParts: []string{"front"},
+				},
+			}},
+			generativeModel: &mockGenerativeModel{GenerateContentFunc: func(ctx context.Context, req *ModelRequest) (*ModelResponse, error) {
+				return &ModelResponse{Text: `{"height":{"value":"170cm","confidence_score":0.9},"member_id":{"value":"ABC123456789","confidence_score":0.9}}`}, nil
+			}},
+		}
+		result, err := client.ExtractDocument(context.Background(), ExtractRequest{
+			DocumentType: "test_doc",
+			FileParts:    map[string]FilePart{"front": {Content: []byte("fake image data"), MimeType: "image/png"}},
+			Options:      ExtractOptions{Masking: true},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if height := result.ExtractedData["height"]; height.Validation != "invalid" || height.Value != "170cm" {
+			t.Errorf("expected invalid height 170cm, but got %+v", height)
+		}
+		if v := result.ExtractedData["member_id"].Value; v != "************6789" {
+			t.Errorf("expected masked member_id, but got %v", v)
+		}
+	})
+}
+
+func TestValidators(t *testing.T) {
+	t.Run("should run the validators declared for each field", func(t *testing.T) {
+		client := &Client{
+			config: &Config{Documents: map[string]Document{
+				"test_doc": {
+					Prompt: "Extract data from this document.",
+					JSONStructure: map[string]FieldSpec{
+						"name":        {Label: "氏名"},
+						"birth_date":  {Label: "生年月日", Type: FieldTypeDate, Validators: []string{"date"}},
+						"expiry_date": {Label: "有効期限", Type: FieldTypeDate, Validators: []string{"date"}},
+						"card_number": {Label: "番号", Validators: []string{"mynumber_checkdigit"}},
+					},
+					ImageParts: []string{"front"},
+				},
+			}},
+			generativeModel: &mockGenerativeModel{GenerateContentFunc: func(ctx context.Context, req *ModelRequest) (*ModelResponse, error) {
+				return &ModelResponse{Text: `{"name":{"value":"見本太郎","confidence_score":0.9},` +
+					`"birth_date":{"value":"平成2年10月8日","confidence_score":0.9},` +
+					`"expiry_date":{"value":"令和10年2月30日","confidence_score":0.9},` +
+					`"card_number":{"value":"123456789018","confidence_score":0.9}}`}, nil
+			}},
+		}
+		result, err := client.ExtractDocument(context.Background(), ExtractRequest{
+			DocumentType: "test_doc",
+			FileParts:    map[string]FilePart{"front": {Content: []byte("fake image data"), MimeType: "image/png"}},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		expected := map[string]string{"name": "", "birth_date": "valid", "expiry_date": "invalid", "card_number": "valid"}
+		for name, want := range expected {
+			if got := result.ExtractedData[name].Validation; got != want {
+				t.Errorf("%s: expected validation %q, but got %q", name, want, got)
+			}
+		}
+		if got := result.ExtractedData["birth_date"].Normalized; got != "1990-10-08" {
+			t.Errorf("expected normalized birth_date 1990-10-08, but got %q", got)
+		}
+		if got := result.ExtractedData["expiry_date"].Normalized; got != "" {
+			t.Errorf("expected no normalized value for an invalid date, but got %q", got)
+		}
+	})
+
+	t.Run("should run custom validators with their reasons", func(t *testing.T) {
+		registry := validation.NewRegistry()
+		registry.Register("employee_id", func(ctx context.Context, field validation.FieldContext) validation.Result {
+			if field.DocumentType != "test_doc" || field.Fields["name"] != "見本太郎" {
+				t.Errorf("unexpected field context: %+v", field)
+			}
+			if !strings.HasPrefix(field.Value, "E") {
+				return validation.Invalid("must start with E")
+			}
+			return validation.Valid()
+		})
+		config := Config{Documents: map[string]Document{
+			"test_doc": {
+				Prompt: "Extract data from this document.",
+				JSONStructure: map[string]FieldSpec{
+					"name":        {Label: "氏名"},
+					"employee_id": {Label: "社員番号", Validators: []string{"employee_id"}},
+				},
+				ImageParts: []string{"front"},
+			},
+		}}
+		model := &mockGenerativeModel{GenerateContentFunc: func(ctx context.Context, req *ModelRequest) (*ModelResponse, error) {
+			return &ModelResponse{Text: `{"name":{"value":"見本太郎","confidence_score":0.9},"employee_id":{"value":"X1234","confidence_score":0.9}}`}, nil
+		}}
+
+		if _, err := NewClientWithConfig(context.Background(), "", "", config, WithGenerativeModel(model)); err == nil {
+			t.Error("expected unknown validator error with the default registry, but got nil")
+		}
+		client, err := NewClientWithConfig(context.Background(), "", "", config, WithGenerativeModel(model), WithValidatorRegistry(registry))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		result, err := client.ExtractDocument(context.Background(), ExtractRequest{
+			DocumentType: "test_doc",
+			FileParts:    map[string]FilePart{"front": {Content: []byte("fake image data"), MimeType: "image/png"}},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		field := result.ExtractedData["employee_id"]
+		expected := []ValidationResult{{Status: validation.StatusInvalid, Rule: "employee_id", Message: "must start with E", Severity: validation.SeverityError}}
+		if field.Validation != "invalid" || !reflect.DeepEqual(field.Validations, expected) {
+			t.Errorf("expected invalid employee_id with reason, but got %+v", field)
+		}
+	})
+
+	t.Run("should reject unknown validators", func(t *testing.T) {
+		config := Config{Documents: map[string]Document{
+			"test_doc": {JSONStructure: map[string]FieldSpec{"name": {Label: "氏名", Validators: []string{"no_such_validator"}}}},
+		}}
+		_, err := NewClientWithConfig(context.Background(), "", "", config, WithGenerativeModel(&mockGenerativeModel{}))
+		if err == nil || !strings.Contains(err.Error(), "no_such_validator") {
+			t.Errorf("expected unknown validator error, but got %v", err)
+		}
+	})
+
+	t.Run("should only refer to registered validators in the embedded config", func(t *testing.T) {
+		config, err := loadDefaultConfig()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := checkValidators(*config, validation.DefaultRegistry); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+}
+
+func TestVerdict(t *testing.T) {
+	registry := validation.NewRegistry()
+	registry.Register("kana_only", func(ctx context.Context, field validation.FieldContext) validation.Result {
+		return validation.Warning("contains kanji")
+	})
+	config := Config{Documents: map[string]Document{
+		"test_doc": {
+			Prompt: "Extract data from this document.",
+			JSONStructure: map[string]FieldSpec{
+				"name":        {Label: "氏名", Validators: []string{"kana_only"}},
+				"card_number": {Label: "番号", Format: "^[0-9]+$", Validators: []string{"mynumber_checkdigit"}},
+			},
+			ImageParts: []string{"front"},
+		},
+	}}
+	request := ExtractRequest{
+		DocumentType: "test_doc",
+		FileParts:    map[string]FilePart{"front": {Content: []byte("fake image data"), MimeType: "image/png"}},
+	}
+	newClient := func(cardNumber string, opts ...ClientOption) *Client {
+		model := &mockGenerativeModel{GenerateContentFunc: func(ctx context.Context, req *ModelRequest) (*ModelResponse, error) {
+			return &ModelResponse{Text: fmt.Sprintf(`{"name":{"value":"見本太郎","confidence_score":0.9},"card_number":{"value":%q,"confidence_score":0.9}}`, cardNumber)}, nil
+		}}
+		opts = append([]ClientOption{WithGenerativeModel(model), WithValidatorRegistry(registry), WithEscalationPolicy(EscalationPolicy{})}, opts...)
+		client, err := NewClientWithConfig(context.Background(), "", "", config, opts...)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return client
+	}
+
+	t.Run("should ask for review when only warnings fail", func(t *testing.T) {
+		result, err := newClient("123456789018").ExtractDocument(context.Background(), request)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result.Verdict != VerdictReview {
+			t.Errorf("expected verdict %s, but got %s", VerdictReview, result.Verdict)
+		}
+		expected := []ValidationResult{{Status: validation.StatusInvalid, Rule: "kana_only", Message: "contains kanji", Severity: validation.SeverityWarning}}
+		if got := result.ExtractedData["name"].Validations; !reflect.DeepEqual(got, expected) {
+			t.Errorf("expected validations %+v, but got %+v", expected, got)
+		}
+	})
+
+	t.Run("should fail and tell why a card number is invalid", func(t *testing.T) {
+		result, err := newClient("12345678901A").ExtractDocument(context.Background(), request)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result.Verdict != VerdictFail {
+			t.Errorf("expected verdict %s, but got %s", VerdictFail, result.Verdict)
+		}
+		var rules []string
+		for _, r := range result.ExtractedData["card_number"].Validations {
+			rules = append(rules, r.Rule+": "+r.Message)
+		}
+		expected := []string{`mynumber_checkdigit: contains a non-digit character 'A'`, "format: does not match ^[0-9]+$"}
+		if !reflect.DeepEqual(rules, expected) {
+			t.Errorf("expected %v, but got %v", expected, rules)
+		}
+	})
+
+	t.Run("should omit structured results in legacy mode", func(t *testing.T) {
+		result, err := newClient("123456789012", WithLegacyValidation()).ExtractDocument(context.Background(), request)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		b, err := json.Marshal(result)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if strings.Contains(string(b), `"validations"`) || strings.Contains(string(b), `"verdict"`) {
+			t.Errorf("expected legacy JSON, but got %s", b)
+		}
+		if result.ExtractedData["card_number"].Validation != "invalid" {
+			t.Errorf("expected invalid card number, but got %+v", result.ExtractedData["card_number"])
+		}
+	})
+}
+
+func TestRules(t *testing.T) {
+	config := Config{Documents: map[string]Document{
+		"test_doc": {
+			Prompt: "Extract data from this document.",
+			JSONStructure: map[string]FieldSpec{
+				"birth_date":    {Label: "生年月日", Type: FieldTypeDate},
+				"issue_date":    {Label: "交付日", Type: FieldTypeDate},
+				"address":       {Label: "住所"},
+				"front_address": {Label: "表面の住所", Optional: true},
+			},
+			ImageParts: []string{"front"},
+			Rules: []RuleSpec{
+				{Rule: "date_order", Fields: []string{"birth_date", "issue_date"}},
+				{Rule: "address_match", Fields: []string{"front_address", "address"}},
+			},
+		},
+	}}
+	newClient := func(response string, opts ...ClientOption) *Client {
+		model := &mockGenerativeModel{GenerateContentFunc: func(ctx context.Context, req *ModelRequest) (*ModelResponse, error) {
+			return &ModelResponse{Text: response}, nil
+		}}
+		opts = append([]ClientOption{WithGenerativeModel(model), WithEscalationPolicy(EscalationPolicy{})}, opts...)
+		client, err := NewClientWithConfig(context.Background(), "", "", config, opts...)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return client
+	}
+	request := ExtractRequest{
+		DocumentType: "test_doc",
+		FileParts:    map[string]FilePart{"front": {Content: []byte("fake image data"), MimeType: "image/png"}},
+	}
+
+	t.Run("should report failed rules as document-level findings", func(t *testing.T) {
+		client := newClient(`{"birth_date":{"value":"平成2年10月8日","confidence_score":0.9},"issue_date":{"value":"昭和60年1月1日","confidence_score":0.9},` +
+			`"address":{"value":"大阪府大阪市北区1-1","confidence_score":0.9},"front_address":{"value":"東京都千代田区霞が関2-1-1","confidence_score":0.9}}`)
+		result, err := client.ExtractDocument(context.Background(), request)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(result.Findings) != 2 {
+			t.Fatalf("expected 2 findings, but got %+v", result.Findings)
+		}
+		if f := result.Findings[0]; f.Rule != "date_order" || f.Severity != validation.SeverityError || !reflect.DeepEqual(f.Fields, []string{"birth_date", "issue_date"}) {
+			t.Errorf("unexpected finding: %+v", f)
+		}
+		if f := result.Findings[1]; f.Rule != "address_match" || f.Severity != validation.SeverityWarning {
+			t.Errorf("unexpected finding: %+v", f)
+		}
+		if result.Verdict != VerdictFail {
+			t.Errorf("expected verdict %s, but got %s", VerdictFail, result.Verdict)
+		}
+	})
+
+	t.Run("should omit findings in legacy mode", func(t *testing.T) {
+		client := newClient(`{"birth_date":{"value":"平成2年10月8日","confidence_score":0.9},"issue_date":{"value":"昭和60年1月1日","confidence_score":0.9},`+
+			`"address":{"value":"東京都千代田区霞が関2-1-1","confidence_score":0.9}}`, WithLegacyValidation())
+		result, err := client.ExtractDocument(context.Background(), request)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result.Findings != nil {
+			t.Errorf("expected no findings, but got %+v", result.Findings)
+		}
+	})
+
+	t.Run("should pass consistent documents", func(t *testing.T) {
+		client := newClient(`{"birth_date":{"value":"昭和60年1月1日","confidence_score":0.9},"issue_date":{"value":"平成25年4月1日","confidence_score":0.9},` +
+			`"address":{"value":"東京都千代田区霞が関2-1-1","confidence_score":0.9}}`)
+		result, err := client.ExtractDocument(context.Background(), request)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(result.Findings) != 0 || result.Verdict != VerdictPass {
+			t.Errorf("expected no findings and verdict pass, but got %+v and %s", result.Findings, result.Verdict)
+		}
+	})
+
+	t.Run("should not reveal masked values in findings", func(t *testing.T) {
+		config, err := loadDefaultConfig()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		model := &mockGenerativeModel{GenerateContentFunc: func(ctx context.Context, req *ModelRequest) (*ModelResponse, error) {
+			return &ModelResponse{Text: `{"name":{"value":"SUZUKI HANAKO","confidence_score":0.9},"passport_number":{"value":"XY7654321","confidence_score":0.9},` +
+				`"mrz_line1":{"value":"P<JPNYAMADA<<TARO<<<<<<<<<<<<<<<<<<<<<<<<<<<","confidence_score":0.9},` +
+				`"mrz_line2":{"value":"XY12345671JPN9001011M3001019<<<<<<<<<<<<<<04","confidence_score":0.9}}`}, nil
+		}}
+		client, err := NewClientWithConfig(context.Background(), "", "", *config, WithGenerativeModel(model), WithEscalationPolicy(EscalationPolicy{}))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		result, err := client.ExtractDocument(context.Background(), ExtractRequest{
+			DocumentType: "passport",
+			FileParts:    request.FileParts,
+			Options:      ExtractOptions{Masking: true},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(result.Findings) == 0 {
+			t.Fatal("expected a finding for the mismatching passport number, but got none")
+		}
+		out, err := json.Marshal(result)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for _, v := range []string{"XY7654321", "XY1234567", "YAMADA", "JPN9001011"} {
+			if strings.Contains(string(out), v) {
+				t.Errorf("expected %s to be masked, but got %s", v, out)
+			}
+		}
+	})
+
+	t.Run("should reject rules with unknown names or fields", func(t *testing.T) {
+		for _, rule := range []RuleSpec{
+			{Rule: "no_such_rule", Fields: []string{"birth_date"}},
+			{Rule: "date_order", Fields: []string{"birth_date", "no_such_field"}},
+		} {
+			config := Config{Documents: map[string]Document{
+				"test_doc": {JSONStructure: map[string]FieldSpec{"birth_date": {Label: "生年月日"}}, Rules: []RuleSpec{rule}},
+			}}
+			if _, err := NewClientWithConfig(context.Background(), "", "", config, WithGenerativeModel(&mockGenerativeModel{})); err == nil {
+				t.Errorf("expected error for rule %+v, but got nil", rule)
+			}
+		}
+	})
+}
+
+func TestDocumentStatus(t *testing.T) {
+	config := Config{Documents: map[string]Document{
+		"test_doc": {
+			Prompt: "Extract data from this document.",
+			JSONStructure: map[string]FieldSpec{
+				"expiry_date": {Label: "有効期限", Type: FieldTypeDate, Validators: []string{"date", "not_expired"}},
+			},
+			ImageParts: []string{"front"},
+		},
+	}}
+	now := time.Date(2024, time.March, 1, 15, 0, 0, 0, time.UTC)
+	request := ExtractRequest{
+		DocumentType: "test_doc",
+		FileParts:    map[string]FilePart{"front": {Content: []byte("fake image data"), MimeType: "image/png"}},
+	}
+
+	testCases := []struct {
+		name     string
+		expiry   string
+		status   DocumentStatus
+		verdict  Verdict
+		warnDays int
+	}{
+		{"should report expired documents", "令和6年2月29日", DocumentStatusExpired, VerdictFail, 30},
+		{"should treat the expiry date as valid", "令和6年3月1日", DocumentStatusExpiringWithin(30), VerdictPass, 30},
+		{"should report documents expiring soon", "令和6年3月31日", DocumentStatusExpiringWithin(30), VerdictPass, 30},
+		{"should report valid documents", "令和6年4月1日", DocumentStatusValid, VerdictPass, 30},
+		{"should not warn when disabled", "令和6年3月1日", DocumentStatusValid, VerdictPass, 0},
+		{"should report no status without a valid date", "不明", "", VerdictFail, 30},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			model := &mockGenerativeModel{GenerateContentFunc: func(ctx context.Context, req *ModelRequest) (*ModelResponse, error) {
+				return &ModelResponse{Text: `{"expiry_date":{"value":"` + tc.expiry + `","confidence_score":0.9}}`}, nil
+			}}
+			client, err := NewClientWithConfig(context.Background(), "", "", config, WithGenerativeModel(model),
+				WithEscalationPolicy(EscalationPolicy{}), WithClock(func() time.Time { return now }), WithExpiryWarningDays(tc.warnDays))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			result, err := client.ExtractDocument(context.Background(), request)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result.Status != tc.status {
+				t.Errorf("expected status %q, but got %q", tc.status, result.Status)
+			}
+			if result.Verdict != tc.verdict {
+				t.Errorf("expected verdict %s, but got %s", tc.verdict, result.Verdict)
+			}
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	t.Run("should apply the normalizations in order", func(t *testing.T) {
+		testCases := []struct {
+			value          string
+			normalizations []Normalization
+			expected       string
+		}{
+			{"１２３４", []Normalization{NormalizeNFKC}, "1234"},
+			{"ｻﾝﾌﾟﾙ", []Normalization{NormalizeNFKC}, "サンプル"},
+			{"霞が関２－１ー１", []Normalization{NormalizeNFKC, NormalizeHyphens}, "霞が関2-1-1"},
+			{"センタ－ビル", []Normalization{NormalizeHyphens}, "センタービル"},
+			{" YAMADA　 TARO ", []Normalization{NormalizeSpaces}, "YAMADA TARO"},
+			{"1234 5678 9018", []Normalization{NormalizeNoSpaces}, "123456789018"},
+			{"第３０１５ １２３４ ５６５０号", []Normalization{NormalizeNFKC, NormalizeNoSpaces, NormalizeDecorations}, "301512345650"},
+			{"№ 12", []Normalization{NormalizeNFKC, NormalizeDecorations}, "12"},
+			{"第一", []Normalization{NormalizeDecorations}, "第一"},
+			{"東京都千代田区霞が関二丁目１番１号", []Normalization{NormalizeAddress}, "東京都千代田区霞が関2-1-1"},
+		}
+		for _, tc := range testCases {
+			if got := normalizeText(tc.value, tc.normalizations); got != tc.expected {
+				t.Errorf("expected %q, but got %q for %q", tc.expected, got, tc.value)
+			}
+		}
+	})
+
+	config := Config{Documents: map[string]Document{
+		"test_doc": {
+			Prompt: "Extract data from this document.",
+			JSONStructure: map[string]FieldSpec{
+				"card_number": {Label: "マイナンバー", Sensitive: true, Normalize: []Normalization{NormalizeNFKC, NormalizeNoSpaces}, Validators: []string{"mynumber_checkdigit"}},
+				"amount":      {Label: "金額", Type: FieldTypeNumber, Normalize: []Normalization{NormalizeNFKC}},
+				"name":        {Label: "氏名"},
+			},
+			ImageParts: []string{"front"},
+		},
+	}}
+	request := ExtractRequest{
+		DocumentType: "test_doc",
+		FileParts:    map[string]FilePart{"front": {Content: []byte("fake image data"), MimeType: "image/png"}},
+	}
+	newClient := func(responses ...string) *Client {
+		var calls int32
+		model := &mockGenerativeModel{GenerateContentFunc: func(ctx context.Context, req *ModelRequest) (*ModelResponse, error) {
+			i := int(atomic.AddInt32(&calls, 1)-1) % len(responses)
+			return &ModelResponse{Text: responses[i]}, nil
+		}}
+		client, err := NewClientWithConfig(context.Background(), "", "", config, WithGenerativeModel(model), WithEscalationPolicy(EscalationPolicy{}))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return client
+	}
+
+	t.Run("should keep the raw value and validate the normalized one", func(t *testing.T) {
+		client := newClient(`{"card_number":{"value":"１２３４ ５６７８ ９０１８","confidence_score":0.9},"amount":{"value":"１，０００","confidence_score":0.9},"name":{"value":"見本　太郎","confidence_score":0.9}}`)
+		result, err := client.ExtractDocument(context.Background(), request)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		card := result.ExtractedData["card_number"]
+		if card.Value != "１２３４ ５６７８ ９０１８" || card.Normalized != "123456789018" {
+			t.Errorf("expected raw and normalized card number, but got %q and %q", card.Value, card.Normalized)
+		}
+		if card.Validation != "valid" {
+			t.Errorf("expected card number to be valid, but got %+v", card.Validations)
+		}
+		if amount := result.ExtractedData["amount"]; amount.Value != 1000.0 {
+			t.Errorf("expected amount 1000, but got %v", amount.Value)
+		}
+		if name := result.ExtractedData["name"]; name.Normalized != "" {
+			t.Errorf("expected no normalized name, but got %q", name.Normalized)
+		}
+	})
+
+	t.Run("should mask the normalized value of sensitive fields", func(t *testing.T) {
+		client := newClient(`{"card_number":{"value":"１２３４ ５６７８ ９０１８","confidence_score":0.9},"amount":{"value":"1000","confidence_score":0.9},"name":{"value":"見本太郎","confidence_score":0.9}}`)
+		req := request
+		req.Options.Masking = true
+		result, err := client.ExtractDocument(context.Background(), req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		card := result.ExtractedData["card_number"]
+		if card.Normalized != "************9018" || card.Value != "************９０１８" {
+			t.Errorf("expected masked values, but got %q and %q", card.Value, card.Normalized)
+		}
+	})
+
+	t.Run("should count normalized values as agreeing between samples", func(t *testing.T) {
+		client := newClient(
+			`{"card_number":{"value":"123456789018","confidence_score":0.9},"amount":{"value":"1000","confidence_score":0.9},"name":{"value":"見本太郎","confidence_score":0.9}}`,
+			`{"card_number":{"value":"１２３４５６７８９０１８","confidence_score":0.9},"amount":{"value":"1000","confidence_score":0.9},"name":{"value":"見本太郎","confidence_score":0.9}}`,
+		)
+		req := request
+		req.Options.Samples = 2
+		result, err := client.ExtractDocument(context.Background(), req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(result.Consensus.DisputedFields) != 0 {
+			t.Errorf("expected no disputed fields, but got %v", result.Consensus.DisputedFields)
+		}
+	})
+
+	t.Run("should reject unknown normalizations", func(t *testing.T) {
+		var spec FieldSpec
+		if err := yaml.Unmarshal([]byte(`{ label: "氏名", normalize: [nfkc, rot13] }`), &spec); err == nil {
+			t.Error("expected error, but got nil")
+		}
+	})
+}
+
+func TestGeminiResponseText(t *testing.T) {
+	t.Run("should return text of the first candidate", func(t *testing.T) {
+		resp := &genai.GenerateContentResponse{
+			Candidates: []*genai.Candidate{{Content: &genai.Content{Parts: []genai.Part{genai.Text("{}")}}}},
+		}
+		text, err := geminiResponseText(resp)
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if text != "{}" {
+			t.Errorf("expected text {}, but got %s", text)
+		}
+	})
+
+	t.Run("should return error when api returns no candidates", func(t *testing.T) {
+		resp := &genai.GenerateContentResponse{
+			Candidates: []*genai.Candidate{},
+		}
+		if _, err := geminiResponseText(resp); !errors.Is(err, ErrNoContent) {
+			t.Errorf("expected error %v, but got %v", ErrNoContent, err)
+		}
+	})
+
+	t.Run("should return error when api returns unexpected format", func(t *testing.T) {
+		resp := &genai.GenerateContentResponse{
+			Candidates: []*genai.Candidate{
+				{
+					Content: &genai.Content{
+						Parts: []genai.Part{
+							genai.ImageData("png", []byte("fake image data")),
+						},
+					},
+				},
+			},
+		}
+		if _, err := geminiResponseText(resp); err == nil {
+			t.Error("expected error, but got nil")
+		}
+	})
+}
+
+func TestParseExtractRequest(t *testing.T) {
+	newRequest := func(t *testing.T, values map[string]string, withImage bool) *http.Request {
+		t.Helper()
+		body := new(bytes.Buffer)
+		writer := multipart.NewWriter(body)
+		for k, v := range values {
+			if err := writer.WriteField(k, v); err != nil {
+				t.Fatalf("failed to write field: %v", err)
+			}
+		}
+		if withImage {
+			part, err := writer.CreateFormFile("image_front", "front.png")
+			if err != nil {
+				t.Fatalf("failed to create form file: %v", err)
+			}
+			part.Write([]byte("fake image data"))
+		}
+		writer.Close()
+
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/extract", body)
+		r.Header.Set("Content-Type", writer.FormDataContentType())
+		return r
+	}
+
+	t.Run("should parse document type, files and options", func(t *testing.T) {
+		r := newRequest(t, map[string]string{
+			"document_type": "driver_license",
+			"masking":       "true",
+			"preprocess":    "true",
+			"timeout":       "30s",
+			"fields":        "name, card_number",
+		}, true)
+
+		req, err := ParseExtractRequest(r)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if req.DocumentType != "driver_license" {
+			t.Errorf("expected document type driver_license, but got %s", req.DocumentType)
+		}
+		if _, ok := req.FileParts["front"]; !ok {
+			t.Error("expected front file part")
+		}
+		expectedOpts := ExtractOptions{
+			Masking:    true,
+			Preprocess: true,
+			Timeout:    30 * time.Second,
+			Fields:     []string{"name", "card_number"},
+		}
+		if !reflect.DeepEqual(req.Options, expectedOpts) {
+			t.Errorf("expected options %+v, but got %+v", expectedOpts, req.Options)
+		}
+	})
+
+	t.Run("should classify when document type is omitted", func(t *testing.T) {
+		r := newRequest(t, map[string]string{}, true)
+		req, err := ParseExtractRequest(r)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if req.DocumentType != DocumentTypeAuto {
+			t.Errorf("expected document type %s, but got %s", DocumentTypeAuto, req.DocumentType)
+		}
+	})
+
+	t.Run("should return error for invalid timeout", func(t *testing.T) {
+		r := newRequest(t, map[string]string{"document_type": "driver_license", "timeout": "soon"}, true)
+		if _, err := ParseExtractRequest(r); err == nil {
+			t.Error("expected error, but got nil")
+		}
+	})
+
+	t.Run("should return error for too many samples", func(t *testing.T) {
+		r := newRequest(t, map[string]string{"document_type": "driver_license", "samples": "1000"}, true)
+		if _, err := ParseExtractRequest(r); err == nil {
+			t.Error("expected error, but got nil")
+		}
+	})
+
+	t.Run("should return error when no image is sent", func(t *testing.T) {
+		r := newRequest(t, map[string]string{"document_type": "driver_license"}, false)
+		if _, err := ParseExtractRequest(r); !errors.Is(err, ErrMissingField) {
+			t.Errorf("expected error %v, but got %v", ErrMissingField, err)
+		}
+	})
+
+	t.Run("should keep the deprecated tuple form working", func(t *testing.T) {
+		r := newRequest(t, map[string]string{"document_type": "passport", "masking": "true"}, true)
+		docType, fileParts, masking, preprocess, err := ParseRequest(r)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if docType != "passport" || len(fileParts) != 1 || !masking || preprocess {
+			t.Errorf("unexpected result: %s %v %v %v", docType, fileParts, masking, preprocess)
+		}
 	})
 }
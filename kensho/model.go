@@ -0,0 +1,60 @@
+package kensho
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNoContent is returned when the model response does not contain any text.
+var ErrNoContent = errors.New("no content generated")
+
+// GenerativeModel is the interface implemented by the model backends kensho can talk to.
+type GenerativeModel interface {
+	GenerateContent(ctx context.Context, req *ModelRequest) (*ModelResponse, error)
+}
+
+// Part is a single piece of model input. It holds either text or, when MIMEType is set,
+// an inline blob such as an image or a PDF.
+type Part struct {
+	Text     string
+	MIMEType string
+	Data     []byte
+}
+
+// TextPart returns a Part holding text.
+func TextPart(text string) Part {
+	return Part{Text: text}
+}
+
+// BlobPart returns a Part holding binary data of the given MIME type.
+func BlobPart(mimeType string, data []byte) Part {
+	return Part{MIMEType: mimeType, Data: data}
+}
+
+// IsBlob reports whether p holds binary data rather than text.
+func (p Part) IsBlob() bool {
+	return p.MIMEType != ""
+}
+
+// ModelRequest is a provider-independent generation request.
+type ModelRequest struct {
+	// Model overrides the default model of the backend when non-empty.
+	Model string
+	Parts []Part
+	// ResponseSchema, when set, constrains the response to JSON matching the schema.
+	ResponseSchema *Schema
+}
+
+// ModelResponse is a provider-independent generation response.
+type ModelResponse struct {
+	Text  string
+	Model string
+	Usage TokenUsage
+}
+
+// TokenUsage reports the number of tokens consumed by a generation request.
+type TokenUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CandidatesTokens int `json:"candidates_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
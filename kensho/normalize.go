@@ -0,0 +1,117 @@
+package kensho
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/y-mitsuyoshi/kensho/kensho/address"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Normalization is a text normalization applied to the value of a field.
+type Normalization string
+
+const (
+	// NormalizeNFKC applies Unicode NFKC normalization, which turns full-width letters,
+	// digits and symbols into their ASCII forms and half-width katakana into full-width.
+	NormalizeNFKC Normalization = "nfkc"
+	// NormalizeHyphens unifies the dashes and long vowel marks: a mark following kana
+	// becomes ー and any other one becomes -.
+	NormalizeHyphens Normalization = "hyphens"
+	// NormalizeSpaces trims the value and collapses runs of spaces into one ASCII space.
+	NormalizeSpaces Normalization = "spaces"
+	// NormalizeNoSpaces removes all spaces.
+	NormalizeNoSpaces Normalization = "no_spaces"
+	// NormalizeDecorations removes the decorations written around numbers, such as 第 and
+	// 号 in 第123号 or the No. prefix.
+	NormalizeDecorations Normalization = "decorations"
+	// NormalizeAddress rewrites a Japanese address in the normalized form of the address
+	// package, e.g. 東京都千代田区霞が関二丁目1番1号 becomes 東京都千代田区霞が関2-1-1.
+	NormalizeAddress Normalization = "address"
+)
+
+// normalizers implements the normalizations.
+var normalizers = map[Normalization]func(string) string{
+	NormalizeNFKC:        norm.NFKC.String,
+	NormalizeHyphens:     unifyHyphens,
+	NormalizeSpaces:      func(s string) string { return strings.Join(strings.Fields(s), " ") },
+	NormalizeNoSpaces:    func(s string) string { return strings.Join(strings.Fields(s), "") },
+	NormalizeDecorations: stripDecorations,
+	NormalizeAddress:     address.Normalize,
+}
+
+// hyphenLike lists the characters read interchangeably as a hyphen or a long vowel mark.
+var hyphenLike = map[rune]bool{
+	'-': true, '‐': true, '‑': true, '‒': true, '–': true, '—': true, '―': true,
+	'−': true, '﹣': true, '－': true, 'ー': true, 'ｰ': true, '─': true, '━': true,
+}
+
+// unifyHyphens replaces the characters of hyphenLike with ー after kana and with - elsewhere.
+func unifyHyphens(s string) string {
+	var b strings.Builder
+	prev := rune(0)
+	for _, r := range s {
+		if hyphenLike[r] {
+			if unicode.In(prev, unicode.Katakana, unicode.Hiragana) || prev == 'ー' {
+				r = 'ー'
+			} else {
+				r = '-'
+			}
+		}
+		b.WriteRune(r)
+		prev = r
+	}
+	return b.String()
+}
+
+// stripDecorations removes 第 and 号 around a number and its No. or № prefix.
+func stripDecorations(s string) string {
+	s = strings.TrimSpace(s)
+	if !strings.ContainsAny(s, "0123456789０１２３４５６７８９") {
+		return s
+	}
+	s = strings.TrimPrefix(s, "第")
+	s = strings.TrimSuffix(s, "号")
+	s = strings.TrimPrefix(s, "№")
+	if len(s) > 2 && strings.EqualFold(s[:2], "no") {
+		rest := strings.TrimSpace(strings.TrimPrefix(s[2:], "."))
+		if rest != "" && rest[0] >= '0' && rest[0] <= '9' {
+			s = rest
+		}
+	}
+	return strings.TrimSpace(s)
+}
+
+// normalizeText applies the normalizations to s in order.
+func normalizeText(s string, normalizations []Normalization) string {
+	for _, n := range normalizations {
+		if f, ok := normalizers[n]; ok {
+			s = f(s)
+		}
+	}
+	return s
+}
+
+// normalizeFields sets the normalized form of the string values of data whose field
+// declares normalizations. The values themselves are kept as returned by the model.
+func normalizeFields(doc Document, data map[string]Field) {
+	for name, field := range data {
+		spec := doc.JSONStructure[name]
+		s, ok := field.Value.(string)
+		if !ok || len(spec.Normalize) == 0 {
+			continue
+		}
+		field.Normalized = normalizeText(s, spec.Normalize)
+		data[name] = field
+	}
+}
+
+// text returns the normalized form of the value of the field if it has one, and its string
+// value otherwise.
+func (f Field) text() (string, bool) {
+	if f.Normalized != "" {
+		return f.Normalized, true
+	}
+	s, ok := f.Value.(string)
+	return s, ok
+}
@@ -0,0 +1,180 @@
+package kensho
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// defaultOpenAIBaseURL is used when no base URL is configured for the OpenAI-compatible provider.
+const defaultOpenAIBaseURL = "https://api.openai.com/v1"
+
+// openAIModel is a GenerativeModel backed by an OpenAI-compatible chat completions API,
+// such as a self-hosted vision model served by vLLM or Ollama.
+type openAIModel struct {
+	baseURL    string
+	apiKey     string
+	modelName  string
+	httpClient *http.Client
+}
+
+func newOpenAIModel(baseURL, apiKey, modelName string, httpClient *http.Client) *openAIModel {
+	if baseURL == "" {
+		baseURL = defaultOpenAIBaseURL
+	}
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &openAIModel{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		apiKey:     apiKey,
+		modelName:  modelName,
+		httpClient: httpClient,
+	}
+}
+
+type openAIChatRequest struct {
+	Model          string                `json:"model"`
+	Messages       []openAIChatMessage   `json:"messages"`
+	ResponseFormat *openAIResponseFormat `json:"response_format,omitempty"`
+}
+
+type openAIResponseFormat struct {
+	Type       string            `json:"type"`
+	JSONSchema *openAIJSONSchema `json:"json_schema,omitempty"`
+}
+
+type openAIJSONSchema struct {
+	Name   string                 `json:"name"`
+	Schema map[string]interface{} `json:"schema"`
+}
+
+type openAIChatMessage struct {
+	Role    string              `json:"role"`
+	Content []openAIContentPart `json:"content"`
+}
+
+type openAIContentPart struct {
+	Type     string          `json:"type"`
+	Text     string          `json:"text,omitempty"`
+	ImageURL *openAIImageURL `json:"image_url,omitempty"`
+	File     *openAIFile     `json:"file,omitempty"`
+}
+
+type openAIImageURL struct {
+	URL string `json:"url"`
+}
+
+type openAIFile struct {
+	Filename string `json:"filename"`
+	FileData string `json:"file_data"`
+}
+
+type openAIChatResponse struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Message struct {
+			Content string `json:"content"`
+		} `json:"message"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+		TotalTokens      int `json:"total_tokens"`
+	} `json:"usage"`
+}
+
+// GenerateContent implements GenerativeModel.
+func (m *openAIModel) GenerateContent(ctx context.Context, req *ModelRequest) (*ModelResponse, error) {
+	modelName := m.modelName
+	if req.Model != "" {
+		modelName = req.Model
+	}
+
+	content := make([]openAIContentPart, 0, len(req.Parts))
+	for _, p := range req.Parts {
+		if !p.IsBlob() {
+			content = append(content, openAIContentPart{Type: "text", Text: p.Text})
+			continue
+		}
+		dataURL := fmt.Sprintf("data:%s;base64,%s", p.MIMEType, base64.StdEncoding.EncodeToString(p.Data))
+		if strings.HasPrefix(p.MIMEType, "image/") {
+			content = append(content, openAIContentPart{Type: "image_url", ImageURL: &openAIImageURL{URL: dataURL}})
+		} else {
+			content = append(content, openAIContentPart{Type: "file", File: &openAIFile{Filename: "document", FileData: dataURL}})
+		}
+	}
+
+	chatReq := openAIChatRequest{
+		Model:    modelName,
+		Messages: []openAIChatMessage{{Role: "user", Content: content}},
+	}
+	if req.ResponseSchema != nil {
+		chatReq.ResponseFormat = &openAIResponseFormat{
+			Type:       "json_schema",
+			JSONSchema: &openAIJSONSchema{Name: "extraction", Schema: req.ResponseSchema.jsonSchema()},
+		}
+	}
+
+	body, err := json.Marshal(chatReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal chat completions request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, m.baseURL+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create chat completions request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if m.apiKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+m.apiKey)
+	}
+
+	httpResp, err := m.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("chat completions request failed: %w", err)
+	}
+	defer httpResp.Body.Close()
+
+	respBody, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read chat completions response: %w", err)
+	}
+	if httpResp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("chat completions API returned %s: %s", httpResp.Status, strings.TrimSpace(string(respBody)))
+		if sentinel := classifyHTTPStatus(httpResp.StatusCode); sentinel != nil {
+			return nil, fmt.Errorf("%w: %w", sentinel, err)
+		}
+		return nil, err
+	}
+
+	var chatResp openAIChatResponse
+	if err := json.Unmarshal(respBody, &chatResp); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal chat completions response: %w", err)
+	}
+	if len(chatResp.Choices) > 0 && chatResp.Choices[0].FinishReason == "content_filter" {
+		return nil, fmt.Errorf("%w: finish reason content_filter", ErrSafetyBlocked)
+	}
+	if len(chatResp.Choices) == 0 || chatResp.Choices[0].Message.Content == "" {
+		return nil, ErrNoContent
+	}
+
+	if chatResp.Model != "" {
+		modelName = chatResp.Model
+	}
+	return &ModelResponse{
+		Text:  chatResp.Choices[0].Message.Content,
+		Model: modelName,
+		Usage: TokenUsage{
+			PromptTokens:     chatResp.Usage.PromptTokens,
+			CandidatesTokens: chatResp.Usage.CompletionTokens,
+			TotalTokens:      chatResp.Usage.TotalTokens,
+		},
+	}, nil
+}
@@ -0,0 +1,186 @@
+package kensho
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOpenAIModel(t *testing.T) {
+	t.Run("should send text and images as a chat completion", func(t *testing.T) {
+		var got openAIChatRequest
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/v1/chat/completions" {
+				t.Errorf("unexpected path: %s", r.URL.Path)
+			}
+			if auth := r.Header.Get("Authorization"); auth != "Bearer secret" {
+				t.Errorf("unexpected authorization header: %s", auth)
+			}
+			if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+				t.Errorf("failed to decode request: %v", err)
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"model":"served-model","choices":[{"message":{"role":"assistant","content":"{\"name\":{\"value\":\"見本太郎\",\"confidence_score\":0.9}}"},"finish_reason":"stop"}],"usage":{"prompt_tokens":100,"completion_tokens":20,"total_tokens":120}}`))
+		}))
+		defer server.Close()
+
+		model := newOpenAIModel(server.URL+"/v1/", "secret", "vision-model", server.Client())
+		resp, err := model.GenerateContent(context.Background(), &ModelRequest{
+			Parts: []Part{TextPart("prompt"), BlobPart("image/png", []byte{0x89, 0x50})},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if got.Model != "vision-model" {
+			t.Errorf("expected model vision-model, but got %s", got.Model)
+		}
+		if len(got.Messages) != 1 || len(got.Messages[0].Content) != 2 {
+			t.Fatalf("unexpected messages: %+v", got.Messages)
+		}
+		if c := got.Messages[0].Content[0]; c.Type != "text" || c.Text != "prompt" {
+			t.Errorf("unexpected text part: %+v", c)
+		}
+		if c := got.Messages[0].Content[1]; c.Type != "image_url" || c.ImageURL == nil || c.ImageURL.URL != "data:image/png;base64,iVA=" {
+			t.Errorf("unexpected image part: %+v", c)
+		}
+
+		if resp.Text != `{"name":{"value":"見本太郎","confidence_score":0.9}}` {
+			t.Errorf("unexpected text: %s", resp.Text)
+		}
+		if resp.Model != "served-model" {
+			t.Errorf("expected model served-model, but got %s", resp.Model)
+		}
+		expectedUsage := TokenUsage{PromptTokens: 100, CandidatesTokens: 20, TotalTokens: 120}
+		if resp.Usage != expectedUsage {
+			t.Errorf("expected usage %+v, but got %+v", expectedUsage, resp.Usage)
+		}
+	})
+
+	t.Run("should use the model override of the request", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var req openAIChatRequest
+			json.NewDecoder(r.Body).Decode(&req)
+			if req.Model != "override-model" {
+				t.Errorf("expected model override-model, but got %s", req.Model)
+			}
+			w.Write([]byte(`{"choices":[{"message":{"content":"{}"}}]}`))
+		}))
+		defer server.Close()
+
+		model := newOpenAIModel(server.URL, "", "vision-model", server.Client())
+		resp, err := model.GenerateContent(context.Background(), &ModelRequest{Model: "override-model", Parts: []Part{TextPart("prompt")}})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if resp.Model != "override-model" {
+			t.Errorf("expected model override-model, but got %s", resp.Model)
+		}
+	})
+
+	t.Run("should request a json schema response format", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var req map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&req)
+			format, _ := req["response_format"].(map[string]interface{})
+			if format["type"] != "json_schema" {
+				t.Errorf("unexpected response_format: %v", req["response_format"])
+			}
+			w.Write([]byte(`{"choices":[{"message":{"content":"{}"}}]}`))
+		}))
+		defer server.Close()
+
+		model := newOpenAIModel(server.URL, "", "vision-model", server.Client())
+		schema := buildResponseSchema(Document{JSONStructure: map[string]FieldSpec{"name": {Label: "氏名"}}})
+		if _, err := model.GenerateContent(context.Background(), &ModelRequest{Parts: []Part{TextPart("prompt")}, ResponseSchema: schema}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("should return error for non-200 responses", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, `{"error":"boom"}`, http.StatusInternalServerError)
+		}))
+		defer server.Close()
+
+		model := newOpenAIModel(server.URL, "", "vision-model", server.Client())
+		if _, err := model.GenerateContent(context.Background(), &ModelRequest{Parts: []Part{TextPart("prompt")}}); err == nil {
+			t.Error("expected error, but got nil")
+		}
+	})
+
+	t.Run("should classify rate limit and availability errors", func(t *testing.T) {
+		for status, expected := range map[int]error{
+			http.StatusTooManyRequests:    ErrRateLimited,
+			http.StatusServiceUnavailable: ErrModelUnavailable,
+		} {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, `{"error":"try later"}`, status)
+			}))
+
+			model := newOpenAIModel(server.URL, "", "vision-model", server.Client())
+			_, err := model.GenerateContent(context.Background(), &ModelRequest{Parts: []Part{TextPart("prompt")}})
+			if !errors.Is(err, expected) {
+				t.Errorf("status %d: expected error %v, but got %v", status, expected, err)
+			}
+			server.Close()
+		}
+	})
+
+	t.Run("should report content filtered responses as safety blocked", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"choices":[{"message":{"content":""},"finish_reason":"content_filter"}]}`))
+		}))
+		defer server.Close()
+
+		model := newOpenAIModel(server.URL, "", "vision-model", server.Client())
+		_, err := model.GenerateContent(context.Background(), &ModelRequest{Parts: []Part{TextPart("prompt")}})
+		if !errors.Is(err, ErrSafetyBlocked) {
+			t.Errorf("expected error %v, but got %v", ErrSafetyBlocked, err)
+		}
+	})
+
+	t.Run("should return error when no choices are returned", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"choices":[]}`))
+		}))
+		defer server.Close()
+
+		model := newOpenAIModel(server.URL, "", "vision-model", server.Client())
+		_, err := model.GenerateContent(context.Background(), &ModelRequest{Parts: []Part{TextPart("prompt")}})
+		if !errors.Is(err, ErrNoContent) {
+			t.Errorf("expected error %v, but got %v", ErrNoContent, err)
+		}
+	})
+
+	t.Run("should extract through the client", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"choices":[{"message":{"content":"{\"name\":{\"value\":\"見本太郎\",\"confidence_score\":0.9}}"}}]}`))
+		}))
+		defer server.Close()
+
+		config := Config{Documents: map[string]Document{
+			"test_doc": {Prompt: "Extract data from this document.", ImageParts: []string{"front"}},
+		}}
+		client, err := NewClientWithConfig(context.Background(), "", "vision-model", config,
+			WithProvider(ProviderOpenAI), WithBaseURL(server.URL), WithHTTPClient(server.Client()))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		defer client.Close()
+
+		result, err := client.ExtractDocument(context.Background(), ExtractRequest{
+			DocumentType: "test_doc",
+			FileParts:    map[string]FilePart{"front": {Content: []byte("fake image data"), MimeType: "image/png"}},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result.ExtractedData["name"].Value != "見本太郎" {
+			t.Errorf("unexpected result: %+v", result.ExtractedData)
+		}
+	})
+}
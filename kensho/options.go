@@ -0,0 +1,140 @@
+package kensho
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/y-mitsuyoshi/kensho/kensho/validation"
+)
+
+// Provider selects the model backend used by a Client.
+type Provider string
+
+const (
+	// ProviderGemini uses the Gemini API. It is the default provider.
+	ProviderGemini Provider = "gemini"
+	// ProviderOpenAI uses an OpenAI-compatible chat completions API.
+	ProviderOpenAI Provider = "openai"
+)
+
+// ClientOption configures a Client created by NewClient and its variants.
+type ClientOption func(*clientOptions)
+
+type clientOptions struct {
+	provider         Provider
+	baseURL          string
+	httpClient       *http.Client
+	model            GenerativeModel
+	retryPolicy      RetryPolicy
+	repairAttempts   int
+	fallbackModels   []string
+	escalation       EscalationPolicy
+	prices           PriceTable
+	validators       *validation.Registry
+	clock            func() time.Time
+	expiryWarning    int
+	legacyValidation bool
+}
+
+// WithProvider selects the model backend. The default is ProviderGemini.
+func WithProvider(provider Provider) ClientOption {
+	return func(o *clientOptions) {
+		o.provider = provider
+	}
+}
+
+// WithBaseURL sets the base URL of the OpenAI-compatible API, e.g. "http://localhost:8000/v1".
+func WithBaseURL(baseURL string) ClientOption {
+	return func(o *clientOptions) {
+		o.baseURL = baseURL
+	}
+}
+
+// WithHTTPClient sets the HTTP client used to reach the model backend.
+func WithHTTPClient(httpClient *http.Client) ClientOption {
+	return func(o *clientOptions) {
+		o.httpClient = httpClient
+	}
+}
+
+// WithGenerativeModel makes the Client use the given backend instead of creating one.
+func WithGenerativeModel(model GenerativeModel) ClientOption {
+	return func(o *clientOptions) {
+		o.model = model
+	}
+}
+
+// WithRetryPolicy sets how failed model calls are retried. The default is DefaultRetryPolicy.
+func WithRetryPolicy(policy RetryPolicy) ClientOption {
+	return func(o *clientOptions) {
+		o.retryPolicy = policy
+	}
+}
+
+// WithRepairAttempts sets how many times the model is re-asked when its response is not
+// valid JSON or misses declared fields. Zero disables the repair loop. The default is
+// DefaultRepairAttempts.
+func WithRepairAttempts(n int) ClientOption {
+	return func(o *clientOptions) {
+		o.repairAttempts = n
+	}
+}
+
+// WithFallbackModels appends models to the model chain of the client. The model passed to
+// NewClient is tried first and the fallback models are tried in order when the escalation
+// policy rejects the result of the previous one.
+func WithFallbackModels(models ...string) ClientOption {
+	return func(o *clientOptions) {
+		o.fallbackModels = append(o.fallbackModels, models...)
+	}
+}
+
+// WithEscalationPolicy sets when the next model of the chain is tried. The default is
+// DefaultEscalationPolicy.
+func WithEscalationPolicy(policy EscalationPolicy) ClientOption {
+	return func(o *clientOptions) {
+		o.escalation = policy
+	}
+}
+
+// WithPriceTable sets the prices used to estimate the cost of extractions. The default
+// is DefaultPriceTable; models missing from the table are counted at no cost.
+func WithPriceTable(prices PriceTable) ClientOption {
+	return func(o *clientOptions) {
+		o.prices = prices
+	}
+}
+
+// WithValidatorRegistry sets the registry the validators named in the configuration are
+// resolved from. The default is validation.DefaultRegistry.
+func WithValidatorRegistry(registry *validation.Registry) ClientOption {
+	return func(o *clientOptions) {
+		o.validators = registry
+	}
+}
+
+// WithLegacyValidation omits Field.Validations, ExtractionResult.Verdict and
+// ExtractionResult.Findings from the results of the client, so that they serialize to the
+// JSON of earlier versions where the validation of a field is a bare valid or invalid
+// string.
+func WithLegacyValidation() ClientOption {
+	return func(o *clientOptions) {
+		o.legacyValidation = true
+	}
+}
+
+// WithClock sets the function returning the current time, against which the expiry of
+// documents is checked. The default is time.Now.
+func WithClock(clock func() time.Time) ClientOption {
+	return func(o *clientOptions) {
+		o.clock = clock
+	}
+}
+
+// WithExpiryWarningDays sets how many days before its expiry date a document is reported
+// as expiring. Zero disables the warning. The default is DefaultExpiryWarningDays.
+func WithExpiryWarningDays(days int) ClientOption {
+	return func(o *clientOptions) {
+		o.expiryWarning = days
+	}
+}
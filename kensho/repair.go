@@ -0,0 +1,180 @@
+package kensho
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DefaultRepairAttempts is the number of times clients created with NewClient and its
+// variants re-ask the model when its response is invalid or incomplete.
+const DefaultRepairAttempts = 2
+
+// modelOutput is a model response parsed into fields.
+type modelOutput struct {
+	model    string
+	raw      string
+	data     map[string]Field
+	attempts int
+}
+
+// generateFields asks the model for the fields of doc and parses its response.
+// Responses that are not valid JSON are first repaired locally and otherwise sent back
+// to the model with a correction instruction; responses that lack fields declared in
+// the JSON structure are re-prompted as well. Both are retried up to the repair
+// attempts of the client. When fields are still missing after the last attempt, or a
+// re-prompt fails, the most recent parsed response is returned.
+func (c *Client) generateFields(ctx context.Context, doc Document, req *ModelRequest, fields []string) (*modelOutput, error) {
+	var (
+		best    *modelOutput
+		lastErr error
+		parts   = req.Parts
+	)
+
+	for attempt := 1; attempt <= c.repairAttempts+1; attempt++ {
+		resp, err := c.generate(ctx, &ModelRequest{
+			Model:          req.Model,
+			Parts:          parts,
+			ResponseSchema: req.ResponseSchema,
+		})
+		if err != nil {
+			if best != nil {
+				return best, nil
+			}
+			return nil, fmt.Errorf("failed to generate content: %w", err)
+		}
+
+		// Backends honouring the response schema return bare JSON; sanitizing is kept for
+		// documents without a JSON structure and backends that ignore the schema.
+		cleaned := sanitizeJSONResponse(resp.Text)
+		data, err := parseFields(cleaned)
+		if err != nil {
+			if repaired := repairJSON(cleaned); repaired != cleaned {
+				if repairedData, repairErr := parseFields(repaired); repairErr == nil {
+					cleaned, data, err = repaired, repairedData, nil
+				}
+			}
+		}
+		if err != nil {
+			lastErr = fmt.Errorf("failed to unmarshal JSON from response: %w (raw response: %s)", err, cleaned)
+			parts = withInstruction(req.Parts, invalidJSONInstruction(cleaned, err))
+			continue
+		}
+
+		best = &modelOutput{model: resp.Model, raw: cleaned, data: data, attempts: attempt}
+		missing := missingFields(doc, data, fields)
+		if len(missing) == 0 {
+			return best, nil
+		}
+		parts = withInstruction(req.Parts, missingFieldsInstruction(missing))
+	}
+
+	if best != nil {
+		return best, nil
+	}
+	return nil, lastErr
+}
+
+// parseFields unmarshals a model response into fields.
+func parseFields(s string) (map[string]Field, error) {
+	var data map[string]Field
+	if err := json.Unmarshal([]byte(s), &data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// missingFields returns the required fields of the JSON structure of doc that are absent
+// from data, limited to fields when it is not empty. Fields present with a null value are
+// not reported since the prompts ask the model to return null for unreadable values.
+func missingFields(doc Document, data map[string]Field, fields []string) []string {
+	var missing []string
+	for name, spec := range doc.JSONStructure {
+		if spec.Optional || len(fields) > 0 && !containsString(fields, name) {
+			continue
+		}
+		if _, ok := data[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// withInstruction returns a copy of parts followed by a correction instruction.
+func withInstruction(parts []Part, instruction string) []Part {
+	out := make([]Part, 0, len(parts)+1)
+	out = append(out, parts...)
+	return append(out, TextPart(instruction))
+}
+
+func invalidJSONInstruction(previous string, err error) string {
+	return fmt.Sprintf("\n**Correction**: Your previous response could not be parsed as JSON (%v).\n"+
+		"Previous response:\n%s\n"+
+		"Return **only** the corrected, single minified JSON object following the JSON Structure above.", err, previous)
+}
+
+func missingFieldsInstruction(missing []string) string {
+	return fmt.Sprintf("\n**Correction**: Your previous response did not include the following fields: %s.\n"+
+		"Return **only** the complete, single minified JSON object including these fields. "+
+		"Use `null` as the value of a field that cannot be read.", strings.Join(missing, ", "))
+}
+
+// repairJSON fixes common syntax errors of model generated JSON: single-quoted strings,
+// including their \' escapes, and trailing commas before a closing brace or bracket.
+func repairJSON(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+
+	var quote byte // the quote character of the string being read, or 0
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case quote != 0:
+			switch {
+			case ch == '\\' && i+1 < len(s):
+				i++
+				// \' is not a JSON escape, and needs none in a double-quoted string.
+				if s[i] != '\'' || quote != '\'' {
+					b.WriteByte(ch)
+				}
+				b.WriteByte(s[i])
+				continue
+			case ch == quote:
+				quote = 0
+				b.WriteByte('"')
+				continue
+			case ch == '"' && quote == '\'':
+				b.WriteString(`\"`)
+				continue
+			}
+			b.WriteByte(ch)
+		case ch == '"' || ch == '\'':
+			quote = ch
+			b.WriteByte('"')
+		case ch == ',':
+			j := i + 1
+			for j < len(s) && strings.ContainsRune(" \t\r\n", rune(s[j])) {
+				j++
+			}
+			if j < len(s) && (s[j] == '}' || s[j] == ']') {
+				continue
+			}
+			b.WriteByte(ch)
+		default:
+			b.WriteByte(ch)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,107 @@
+package kensho
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// ErrRateLimited is returned when the model backend rejects a request because of rate limits or quota.
+var ErrRateLimited = errors.New("rate limited by model backend")
+
+// ErrModelUnavailable is returned when the model backend is temporarily unavailable.
+var ErrModelUnavailable = errors.New("model backend unavailable")
+
+// ErrSafetyBlocked is returned when the model backend blocks the request or response for safety reasons.
+var ErrSafetyBlocked = errors.New("blocked by safety filters")
+
+// RetryPolicy controls how failed model calls are retried. Only rate limit and
+// availability errors are retried.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one.
+	// Values below 2 disable retries.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between two attempts.
+	MaxBackoff time.Duration
+	// Multiplier is applied to the delay after every retry.
+	Multiplier float64
+	// Jitter randomizes each delay by up to this fraction, e.g. 0.2 for ±20%.
+	Jitter float64
+}
+
+// DefaultRetryPolicy is the retry policy of clients created with NewClient and its variants.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    3,
+	InitialBackoff: time.Second,
+	MaxBackoff:     30 * time.Second,
+	Multiplier:     2,
+	Jitter:         0.2,
+}
+
+// backoff returns the delay before the given retry, starting at 1.
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+	d := float64(p.InitialBackoff) * math.Pow(multiplier, float64(retry-1))
+	if p.MaxBackoff > 0 && d > float64(p.MaxBackoff) {
+		d = float64(p.MaxBackoff)
+	}
+	if p.Jitter > 0 {
+		d *= 1 + p.Jitter*(2*rand.Float64()-1)
+	}
+	return time.Duration(d)
+}
+
+// classifyHTTPStatus returns the sentinel error matching an HTTP status code of a model
+// backend, or nil when the status has no dedicated error.
+func classifyHTTPStatus(code int) error {
+	switch code {
+	case http.StatusTooManyRequests:
+		return ErrRateLimited
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return ErrModelUnavailable
+	}
+	return nil
+}
+
+// isRetryable reports whether a failed model call may succeed when retried.
+func isRetryable(err error) bool {
+	return errors.Is(err, ErrRateLimited) || errors.Is(err, ErrModelUnavailable)
+}
+
+// generate calls the model backend, retrying transient failures according to the
+// retry policy of the client. It gives up early when the context would expire
+// before the next attempt.
+func (c *Client) generate(ctx context.Context, req *ModelRequest) (*ModelResponse, error) {
+	for attempt := 1; ; attempt++ {
+		resp, err := c.generativeModel.GenerateContent(ctx, req)
+		if err == nil {
+			recordUsage(ctx, req, resp)
+			return resp, nil
+		}
+		if !isRetryable(err) || attempt >= c.retryPolicy.MaxAttempts {
+			return nil, err
+		}
+
+		delay := c.retryPolicy.backoff(attempt)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			return nil, err
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("%w (last error: %w)", ctx.Err(), err)
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package kensho
+
+import "sort"
+
+// SchemaType is the JSON type described by a Schema.
+type SchemaType string
+
+const (
+	SchemaTypeObject  SchemaType = "object"
+	SchemaTypeString  SchemaType = "string"
+	SchemaTypeNumber  SchemaType = "number"
+	SchemaTypeBoolean SchemaType = "boolean"
+)
+
+// Schema describes the JSON document the model must return. It is translated into the
+// structured output feature of each backend.
+type Schema struct {
+	Type        SchemaType
+	Description string
+	Nullable    bool
+	Enum        []string
+	Properties  map[string]*Schema
+	Required    []string
+}
+
+// jsonSchema returns s as a JSON Schema document.
+func (s *Schema) jsonSchema() map[string]interface{} {
+	out := map[string]interface{}{}
+	if s.Nullable {
+		out["type"] = []string{string(s.Type), "null"}
+	} else {
+		out["type"] = string(s.Type)
+	}
+	if s.Description != "" {
+		out["description"] = s.Description
+	}
+	if len(s.Enum) > 0 {
+		out["enum"] = s.Enum
+	}
+	if s.Type == SchemaTypeObject {
+		props := make(map[string]interface{}, len(s.Properties))
+		for name, prop := range s.Properties {
+			props[name] = prop.jsonSchema()
+		}
+		out["properties"] = props
+		out["additionalProperties"] = false
+		if len(s.Required) > 0 {
+			out["required"] = s.Required
+		}
+	}
+	return out
+}
+
+// valueSchema returns the schema of the value of a field. Dates are requested as strings
+// so that they are returned as written on the document.
+func valueSchema(spec FieldSpec) *Schema {
+	schema := &Schema{Type: SchemaTypeString, Description: spec.Label, Nullable: true}
+	switch spec.Type {
+	case FieldTypeNumber:
+		schema.Type = SchemaTypeNumber
+	case FieldTypeBool:
+		schema.Type = SchemaTypeBoolean
+	case FieldTypeEnum:
+		schema.Enum = spec.Enum
+	}
+	return schema
+}
+
+// buildResponseSchema builds the response schema of a document type from its JSON structure.
+// Every field becomes an object holding the value and its confidence score, next to the
+// forgery warning. It returns nil when the document does not declare a JSON structure.
+func buildResponseSchema(doc Document) *Schema {
+	if len(doc.JSONStructure) == 0 {
+		return nil
+	}
+
+	schema := &Schema{
+		Type:       SchemaTypeObject,
+		Properties: make(map[string]*Schema, len(doc.JSONStructure)+1),
+	}
+	for name, spec := range doc.JSONStructure {
+		schema.Properties[name] = &Schema{
+			Type:        SchemaTypeObject,
+			Description: spec.Label,
+			Properties: map[string]*Schema{
+				"value":            valueSchema(spec),
+				"confidence_score": {Type: SchemaTypeNumber, Description: "0.0-1.0"},
+			},
+			Required: []string{"value", "confidence_score"},
+		}
+		schema.Required = append(schema.Required, name)
+	}
+	sort.Strings(schema.Required)
+
+	schema.Properties["forgery_warning"] = &Schema{
+		Type: SchemaTypeObject,
+		Properties: map[string]*Schema{
+			"has_signs_of_forgery": {Type: SchemaTypeBoolean},
+			"reason":               {Type: SchemaTypeString, Description: "string describing evidence"},
+		},
+		Required: []string{"has_signs_of_forgery", "reason"},
+	}
+	schema.Required = append(schema.Required, "forgery_warning")
+
+	return schema
+}
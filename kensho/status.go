@@ -0,0 +1,61 @@
+package kensho
+
+import (
+	"fmt"
+	"time"
+)
+
+// DocumentStatus tells whether a document is still valid at the time of extraction.
+type DocumentStatus string
+
+const (
+	// DocumentStatusValid means that the document has not expired.
+	DocumentStatusValid DocumentStatus = "valid"
+	// DocumentStatusExpired means that the expiry date of the document has passed.
+	DocumentStatusExpired DocumentStatus = "expired"
+)
+
+// DocumentStatusExpiringWithin returns the status of a document that expires within the
+// given number of days, e.g. expiring_within_30_days.
+func DocumentStatusExpiringWithin(days int) DocumentStatus {
+	return DocumentStatus(fmt.Sprintf("expiring_within_%d_days", days))
+}
+
+// DefaultExpiryWarningDays is the number of days before expiry from which a document is
+// reported as expiring.
+const DefaultExpiryWarningDays = 30
+
+// expiryField is the field the status of a document is computed from.
+const expiryField = "expiry_date"
+
+// now returns the current time of the clock of the client.
+func (c *Client) now() time.Time {
+	if c.clock != nil {
+		return c.clock()
+	}
+	return time.Now()
+}
+
+// documentStatus returns the status of a document from the normalized value of its
+// expiry date. A document is valid through its expiry date. Documents without a valid
+// expiry date have no status.
+func (c *Client) documentStatus(data map[string]Field) DocumentStatus {
+	field, ok := data[expiryField]
+	if !ok || field.Normalized == "" {
+		return ""
+	}
+	expiry, err := time.Parse("2006-01-02", field.Normalized)
+	if err != nil {
+		return ""
+	}
+
+	now := c.now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	switch {
+	case expiry.Before(today):
+		return DocumentStatusExpired
+	case c.expiryWarningDays > 0 && !expiry.After(today.AddDate(0, 0, c.expiryWarningDays)):
+		return DocumentStatusExpiringWithin(c.expiryWarningDays)
+	}
+	return DocumentStatusValid
+}
@@ -0,0 +1,170 @@
+package kensho
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+// ModelPrice is the price of a model in USD per million tokens.
+type ModelPrice struct {
+	InputPerMillionTokens  float64 `json:"input_per_million_tokens" yaml:"input_per_million_tokens"`
+	OutputPerMillionTokens float64 `json:"output_per_million_tokens" yaml:"output_per_million_tokens"`
+}
+
+// PriceTable maps model names to their prices. A model matches the longest key that is a
+// prefix of its name, so "gemini-2.5-flash" also prices "gemini-2.5-flash-001".
+type PriceTable map[string]ModelPrice
+
+// DefaultPriceTable holds the Gemini list prices for prompts up to 200k tokens at the time
+// of writing. Override it with WithPriceTable to match your contract.
+var DefaultPriceTable = PriceTable{
+	"gemini-2.5-pro":        {InputPerMillionTokens: 1.25, OutputPerMillionTokens: 10},
+	"gemini-2.5-flash":      {InputPerMillionTokens: 0.30, OutputPerMillionTokens: 2.50},
+	"gemini-2.5-flash-lite": {InputPerMillionTokens: 0.10, OutputPerMillionTokens: 0.40},
+}
+
+// lookup returns the price of model.
+func (t PriceTable) lookup(model string) (ModelPrice, bool) {
+	var (
+		price   ModelPrice
+		matched string
+		found   bool
+	)
+	for name, p := range t {
+		if strings.HasPrefix(model, name) && len(name) > len(matched) {
+			price, matched, found = p, name, true
+		}
+	}
+	return price, found
+}
+
+// cost returns the estimated cost of usage with model, or zero when the model has no price.
+func (t PriceTable) cost(model string, usage TokenUsage) float64 {
+	price, ok := t.lookup(model)
+	if !ok {
+		return 0
+	}
+	return (float64(usage.PromptTokens)*price.InputPerMillionTokens +
+		float64(usage.CandidatesTokens)*price.OutputPerMillionTokens) / 1e6
+}
+
+// Usage reports the resources consumed by an extraction, summed over every model call
+// it needed (classification, repairs and escalations included).
+type Usage struct {
+	TokenUsage
+	// Model is the model that produced the result.
+	Model string `json:"model,omitempty"`
+	// Calls is the number of successful model calls.
+	Calls int `json:"calls"`
+	// LatencyMS is the wall-clock duration of the extraction in milliseconds.
+	LatencyMS int64 `json:"latency_ms"`
+	// EstimatedCost is the cost in USD estimated from the price table of the client.
+	EstimatedCost float64 `json:"estimated_cost"`
+}
+
+// UsageTotals aggregates the usage of the extractions and classifications of a document
+// type. Usage whose document type could not be determined, such as that of failed
+// classifications, is reported under "unknown".
+type UsageTotals struct {
+	TokenUsage
+	Extractions int `json:"extractions"`
+	// Classifications counts the calls to Client.Classify. The classifications made by
+	// extractions of DocumentTypeAuto are part of the extractions.
+	Classifications int     `json:"classifications"`
+	Failures        int     `json:"failures"`
+	Calls           int     `json:"calls"`
+	EstimatedCost   float64 `json:"estimated_cost"`
+}
+
+// usageCounters holds the usage totals of a client per document type.
+type usageCounters struct {
+	mu     sync.Mutex
+	totals map[string]UsageTotals
+}
+
+// add adds the usage of an extraction of docType to the totals.
+func (u *usageCounters) add(docType string, usage *Usage, failed bool) {
+	u.update(docType, usage, failed, func(t *UsageTotals) { t.Extractions++ })
+}
+
+// addClassification adds the usage of a classification to the totals of docType.
+func (u *usageCounters) addClassification(docType string, usage *Usage, failed bool) {
+	u.update(docType, usage, failed, func(t *UsageTotals) { t.Classifications++ })
+}
+
+// update adds usage to the totals of docType and lets count update the counter of the
+// operation.
+func (u *usageCounters) update(docType string, usage *Usage, failed bool, count func(*UsageTotals)) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if u.totals == nil {
+		u.totals = make(map[string]UsageTotals)
+	}
+	t := u.totals[docType]
+	count(&t)
+	if failed {
+		t.Failures++
+	}
+	t.Calls += usage.Calls
+	t.PromptTokens += usage.PromptTokens
+	t.CandidatesTokens += usage.CandidatesTokens
+	t.TotalTokens += usage.TotalTokens
+	t.EstimatedCost += usage.EstimatedCost
+	u.totals[docType] = t
+}
+
+// Usage returns the usage totals of the client per document type since it was created.
+func (c *Client) Usage() map[string]UsageTotals {
+	c.usage.mu.Lock()
+	defer c.usage.mu.Unlock()
+
+	totals := make(map[string]UsageTotals, len(c.usage.totals))
+	for docType, t := range c.usage.totals {
+		totals[docType] = t
+	}
+	return totals
+}
+
+// usageRecorder sums the usage of the model calls made on behalf of one extraction.
+type usageRecorder struct {
+	mu     sync.Mutex
+	prices PriceTable
+	usage  Usage
+}
+
+type usageRecorderKey struct{}
+
+// withUsageRecorder returns a context whose model calls are recorded in rec.
+func withUsageRecorder(ctx context.Context, rec *usageRecorder) context.Context {
+	return context.WithValue(ctx, usageRecorderKey{}, rec)
+}
+
+// recordUsage adds the usage of a model response to the recorder of ctx, if any.
+func recordUsage(ctx context.Context, req *ModelRequest, resp *ModelResponse) {
+	rec, ok := ctx.Value(usageRecorderKey{}).(*usageRecorder)
+	if !ok {
+		return
+	}
+
+	model := resp.Model
+	if model == "" {
+		model = req.Model
+	}
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.usage.Calls++
+	rec.usage.PromptTokens += resp.Usage.PromptTokens
+	rec.usage.CandidatesTokens += resp.Usage.CandidatesTokens
+	rec.usage.TotalTokens += resp.Usage.TotalTokens
+	rec.usage.EstimatedCost += rec.prices.cost(model, resp.Usage)
+}
+
+// snapshot returns the usage recorded so far.
+func (rec *usageRecorder) snapshot() Usage {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	return rec.usage
+}
@@ -0,0 +1,182 @@
+package kensho
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/y-mitsuyoshi/kensho/kensho/validation"
+)
+
+// validatorRegistry returns the registry the validators of the client are resolved from.
+func (c *Client) validatorRegistry() *validation.Registry {
+	if c.validators != nil {
+		return c.validators
+	}
+	return validation.DefaultRegistry
+}
+
+// validateFields runs the validators declared by the JSON structure of doc on the string
+// values of data, in their normalized form when they have one, and records their results
+// in Field.Validations. Field.Validation is set to invalid when one of them fails and to
+// valid otherwise. Skipped validators are not recorded.
+func (c *Client) validateFields(ctx context.Context, docType string, doc Document, data map[string]Field) {
+	registry := c.validatorRegistry()
+	now := c.now()
+	values := make(map[string]string, len(data))
+	for name, field := range data {
+		if s, ok := field.text(); ok {
+			values[name] = s
+		}
+	}
+
+	for name, field := range data {
+		spec := doc.JSONStructure[name]
+		valueStr, ok := values[name]
+		if len(spec.Validators) == 0 || !ok {
+			continue
+		}
+
+		fieldCtx := validation.FieldContext{DocumentType: docType, Name: name, Value: valueStr, Fields: values, Now: now}
+		for _, validatorName := range spec.Validators {
+			validate, ok := registry.Lookup(validatorName)
+			if !ok {
+				continue
+			}
+			result := validate(ctx, fieldCtx)
+			if result.Status == validation.StatusSkipped || result.Status == "" {
+				continue
+			}
+			field.addValidation(ValidationResult{
+				Status:   result.Status,
+				Rule:     validatorName,
+				Message:  result.Reason,
+				Severity: result.Severity,
+			})
+		}
+		data[name] = field
+	}
+}
+
+// addValidation records the result of a validation rule and updates the validation
+// summary of the field.
+func (f *Field) addValidation(result ValidationResult) {
+	if result.Status == validation.StatusInvalid && result.Severity == "" {
+		result.Severity = validation.SeverityError
+	}
+	f.Validations = append(f.Validations, result)
+	if result.Status == validation.StatusInvalid || f.Validation != string(validation.StatusInvalid) {
+		f.Validation = string(result.Status)
+	}
+}
+
+// verdict returns the overall verdict of result: fail when a field or a cross-field rule
+// failed a validation of error severity, review when only warnings failed, the samples
+// disagreed or the model suspects forgery, and pass otherwise.
+func verdict(result *ExtractionResult) Verdict {
+	v := VerdictPass
+	for _, field := range result.ExtractedData {
+		if failedValidation(field) {
+			return VerdictFail
+		}
+		if field.Validation == string(validation.StatusInvalid) {
+			v = VerdictReview
+		}
+	}
+	for _, finding := range result.Findings {
+		if finding.Severity == validation.SeverityError {
+			return VerdictFail
+		}
+		v = VerdictReview
+	}
+	if result.ForgeryWarning != nil && result.ForgeryWarning.HasSignsOfForgery {
+		v = VerdictReview
+	}
+	if result.Consensus != nil && len(result.Consensus.DisputedFields) > 0 {
+		v = VerdictReview
+	}
+	return v
+}
+
+// stripValidationDetails removes the structured validation results from result so that
+// it serializes like before they were introduced.
+func stripValidationDetails(result *ExtractionResult) {
+	result.Verdict = ""
+	result.Findings = nil
+	for name, field := range result.ExtractedData {
+		field.Validations = nil
+		result.ExtractedData[name] = field
+	}
+}
+
+// checkRules runs the cross-field rules of doc on the string values of data, in their
+// normalized form when they have one, and returns their failures. Rules referring to an
+// unknown name are ignored.
+func (c *Client) checkRules(ctx context.Context, docType string, doc Document, data map[string]Field) []Finding {
+	if len(doc.Rules) == 0 {
+		return nil
+	}
+	registry := c.validatorRegistry()
+	values := make(map[string]string, len(data))
+	for name, field := range data {
+		if s, ok := field.text(); ok {
+			values[name] = s
+		}
+	}
+
+	var findings []Finding
+	for _, spec := range doc.Rules {
+		rule, ok := registry.LookupRule(spec.Rule)
+		if !ok {
+			continue
+		}
+		result := rule(ctx, validation.DocumentContext{DocumentType: docType, Fields: values, Operands: spec.Fields, Now: c.now()})
+		if result.Status != validation.StatusInvalid {
+			continue
+		}
+		severity := result.Severity
+		if spec.Severity != "" {
+			severity = spec.Severity
+		}
+		if severity == "" {
+			severity = validation.SeverityError
+		}
+		findings = append(findings, Finding{
+			ValidationResult: ValidationResult{Status: result.Status, Rule: spec.Rule, Message: result.Reason, Severity: severity},
+			Fields:           spec.Fields,
+		})
+	}
+	return findings
+}
+
+// checkValidators returns an error when config refers to a validator or rule missing from
+// registry, or when a rule refers to a field missing from the JSON structure.
+func checkValidators(config Config, registry *validation.Registry) error {
+	docTypes := make([]string, 0, len(config.Documents))
+	for docType := range config.Documents {
+		docTypes = append(docTypes, docType)
+	}
+	sort.Strings(docTypes)
+
+	for _, docType := range docTypes {
+		doc := config.Documents[docType]
+		for name, spec := range doc.JSONStructure {
+			for _, validatorName := range spec.Validators {
+				if _, ok := registry.Lookup(validatorName); !ok {
+					return fmt.Errorf("%s.%s: unknown validator %q (available: %v)", docType, name, validatorName, registry.Names())
+				}
+			}
+		}
+		for _, spec := range doc.Rules {
+			if _, ok := registry.LookupRule(spec.Rule); !ok {
+				return fmt.Errorf("%s: unknown rule %q (available: %v)", docType, spec.Rule, registry.RuleNames())
+			}
+			for _, name := range spec.Fields {
+				if _, ok := doc.JSONStructure[name]; !ok {
+					return fmt.Errorf("%s: rule %s refers to unknown field %q", docType, spec.Rule, name)
+				}
+			}
+		}
+	}
+	return nil
+}
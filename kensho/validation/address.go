@@ -0,0 +1,20 @@
+package validation
+
+import (
+	"context"
+	"strings"
+
+	"github.com/y-mitsuyoshi/kensho/kensho/address"
+)
+
+// addressValidator checks that an address does not start with a prefecture that does not
+// exist. Addresses without a prefecture are valid.
+func addressValidator(ctx context.Context, field FieldContext) Result {
+	if strings.TrimSpace(field.Value) == "" {
+		return Skipped()
+	}
+	if _, err := address.Parse(field.Value); err != nil {
+		return Invalid(err.Error())
+	}
+	return Valid()
+}
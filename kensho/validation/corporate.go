@@ -0,0 +1,101 @@
+package validation
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// numberReplacer removes the separators written between the digits of an identification
+// number.
+var numberReplacer = strings.NewReplacer(" ", "", "　", "", "-", "")
+
+// normalizeNumber removes the separators of an identification number and converts its
+// full-width characters.
+func normalizeNumber(number string) string {
+	return numberReplacer.Replace(fullWidthReplacer.Replace(number))
+}
+
+// anyDigits accepts every number of the right length, for numbers without a check digit.
+func anyDigits(string) bool {
+	return true
+}
+
+// corporateCheckDigit returns the check digit of the 12-digit base of a corporate number.
+// Weighting the digits 1, 2, 1, ... from the right, the check digit is 9 minus the sum
+// modulo 9.
+func corporateCheckDigit(base string) int {
+	sum := 0
+	for i := 0; i < len(base); i++ {
+		d := int(base[len(base)-1-i] - '0')
+		if i%2 == 1 {
+			d *= 2
+		}
+		sum += d
+	}
+	return 9 - sum%9
+}
+
+// ValidateCorporateNumber validates the check digit of a Japanese Corporate Number
+// (法人番号), 13 digits whose first digit is the check digit of the other 12.
+func ValidateCorporateNumber(number string) bool {
+	number = normalizeNumber(number)
+	if len(number) != 13 {
+		return false
+	}
+	for _, r := range number {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return int(number[0]-'0') == corporateCheckDigit(number[1:])
+}
+
+// ValidateInvoiceRegistrationNumber validates the registration number of a qualified
+// invoice issuer (適格請求書発行事業者登録番号), T followed by a corporate number.
+func ValidateInvoiceRegistrationNumber(number string) bool {
+	return invoiceRegistrationNumberValidator(context.Background(), FieldContext{Value: number}).Status == StatusValid
+}
+
+// ValidateBasicPensionNumber validates the format of a Basic Pension Number (基礎年金番号),
+// a 4-digit office code followed by a 6-digit serial number, e.g. 1234-567890.
+func ValidateBasicPensionNumber(number string) bool {
+	return basicPensionNumberValidator(context.Background(), FieldContext{Value: number}).Status == StatusValid
+}
+
+// CorporateNumber returns the corporate number of a company from its 12-digit company
+// registration number (会社法人等番号) printed on the certificate of registered matters,
+// by prepending the check digit.
+func CorporateNumber(registrationNumber string) (string, error) {
+	base := normalizeNumber(registrationNumber)
+	if result := checkDigits(base, 12, anyDigits); result.Status != StatusValid {
+		return "", fmt.Errorf("invalid company registration number %s: %s", registrationNumber, result.Reason)
+	}
+	return fmt.Sprintf("%d%s", corporateCheckDigit(base), base), nil
+}
+
+// corporateNumberValidator checks a corporate number and tells which part of it is wrong.
+func corporateNumberValidator(ctx context.Context, field FieldContext) Result {
+	return checkDigits(normalizeNumber(field.Value), 13, ValidateCorporateNumber)
+}
+
+// invoiceRegistrationNumberValidator checks the registration number of a qualified invoice
+// issuer and tells which part of it is wrong.
+func invoiceRegistrationNumberValidator(ctx context.Context, field FieldContext) Result {
+	number := strings.ToUpper(normalizeNumber(field.Value))
+	if !strings.HasPrefix(number, "T") {
+		return Invalid("does not start with T")
+	}
+	return checkDigits(number[1:], 13, ValidateCorporateNumber)
+}
+
+// companyRegistrationNumberValidator checks the format of a company registration number,
+// 12 digits such as 0100-01-123456.
+func companyRegistrationNumberValidator(ctx context.Context, field FieldContext) Result {
+	return checkDigits(normalizeNumber(field.Value), 12, anyDigits)
+}
+
+// basicPensionNumberValidator checks the format of a Basic Pension Number.
+func basicPensionNumberValidator(ctx context.Context, field FieldContext) Result {
+	return checkDigits(normalizeNumber(field.Value), 10, anyDigits)
+}
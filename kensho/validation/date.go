@@ -0,0 +1,63 @@
+package validation
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/y-mitsuyoshi/kensho/kensho/address"
+)
+
+// kanjiNumeralRegex matches a run of kanji numerals.
+var kanjiNumeralRegex = regexp.MustCompile(`[〇一二三四五六七八九十百千]+`)
+
+// fullWidthReplacer converts full-width digits and separators to their ASCII forms.
+var fullWidthReplacer = strings.NewReplacer(
+	"０", "0", "１", "1", "２", "2", "３", "3", "４", "4",
+	"５", "5", "６", "6", "７", "7", "８", "8", "９", "9",
+	"．", ".", "／", "/", "－", "-", "　", " ",
+	"Ｍ", "M", "Ｔ", "T", "Ｓ", "S", "Ｈ", "H", "Ｒ", "R",
+)
+
+// ParseJapaneseDate parses a date written on a Japanese document. It accepts Japanese era
+// dates such as 平成30年2月1日 or 令和元年5月1日, their abbreviations such as H30.2.1,
+// R1/5/1 or 平30.2.1, and Gregorian dates such as 2018年2月1日, 2018-02-01, 2018/02/01 or
+// 2018.2.1. Full-width digits, kanji numerals such as 平成三十年二月一日 and spaces such as
+// in 平成30年 2月 1日 are accepted as well.
+func ParseJapaneseDate(dateStr string) (time.Time, error) {
+	original := dateStr
+	dateStr = strings.Join(strings.Fields(fullWidthReplacer.Replace(dateStr)), "")
+	dateStr = kanjiNumeralRegex.ReplaceAllStringFunc(dateStr, func(s string) string {
+		n, _ := address.ParseKanjiNumeral(s)
+		return strconv.Itoa(n)
+	})
+
+	erasMu.RLock()
+	if m := abbreviatedWarekiRegex.FindStringSubmatch(dateStr); m != nil {
+		dateStr = fmt.Sprintf("%s%s年%s月%s日", eraAbbreviations[strings.ToUpper(m[1])], m[2], m[3], m[4])
+	}
+	erasMu.RUnlock()
+
+	// Try parsing as a Japanese era date first.
+	if t, err := warekiToTime(dateStr); err == nil {
+		return t, nil
+	}
+
+	// Fallback for non-era dates or other formats
+	// This part handles formats like YYYY年MM月DD日 (without era) or YYYY-MM-DD
+	dateStr = strings.ReplaceAll(dateStr, "年", "-")
+	dateStr = strings.ReplaceAll(dateStr, "月", "-")
+	dateStr = strings.ReplaceAll(dateStr, "日", "")
+
+	// Try a few common layouts
+	layouts := []string{"2006-1-2", "2006-01-02", "2006/1/2", "2006/01/02", "2006.1.2"}
+	for _, layout := range layouts {
+		if t, err := time.Parse(layout, dateStr); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid date: %s", original)
+}
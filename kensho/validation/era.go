@@ -0,0 +1,211 @@
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Era is a Japanese era. An era lasts from its start date until the day before the start
+// of the next era.
+type Era struct {
+	// Name is the name of the era, e.g. 令和.
+	Name string
+	// Abbreviation is the Latin letter of the era, e.g. R. The first character of Name
+	// is accepted as an abbreviation as well.
+	Abbreviation string
+	// Start is the first day of the era.
+	Start time.Time
+}
+
+// firstYear returns the Gregorian year of the first year (元年) of the era.
+func (e Era) firstYear() int {
+	return e.Start.Year()
+}
+
+// ErrInvalidEra is returned by RegisterEra for an era that cannot follow the known eras.
+var ErrInvalidEra = errors.New("invalid era")
+
+var (
+	erasMu sync.RWMutex
+	// eras lists the known eras by start date.
+	eras = []Era{
+		{Name: "明治", Abbreviation: "M", Start: date(1868, time.October, 23)},
+		{Name: "大正", Abbreviation: "T", Start: date(1912, time.July, 30)},
+		{Name: "昭和", Abbreviation: "S", Start: date(1926, time.December, 25)},
+		{Name: "平成", Abbreviation: "H", Start: date(1989, time.January, 8)},
+		{Name: "令和", Abbreviation: "R", Start: date(2019, time.May, 1)},
+	}
+	// warekiRegex matches era dates such as 平成30年2月1日.
+	warekiRegex *regexp.Regexp
+	// abbreviatedWarekiRegex matches abbreviated era dates such as H30.2.1, R1/5/1 or 平30.2.1.
+	abbreviatedWarekiRegex *regexp.Regexp
+	// eraAbbreviations maps the abbreviations of the eras to their names.
+	eraAbbreviations map[string]string
+)
+
+func init() {
+	compileEras()
+}
+
+// date returns midnight UTC of the given day.
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// compileEras builds the patterns and abbreviations of the eras. erasMu must be held.
+func compileEras() {
+	names := make([]string, 0, len(eras))
+	abbreviations := make(map[string]string, 2*len(eras))
+	var letters strings.Builder
+	for _, e := range eras {
+		names = append(names, regexp.QuoteMeta(e.Name))
+		first := string([]rune(e.Name)[0])
+		abbreviations[first] = e.Name
+		letters.WriteString(regexp.QuoteMeta(first))
+		if e.Abbreviation != "" {
+			abbreviations[strings.ToUpper(e.Abbreviation)] = e.Name
+			letters.WriteString(regexp.QuoteMeta(strings.ToUpper(e.Abbreviation)))
+			letters.WriteString(regexp.QuoteMeta(strings.ToLower(e.Abbreviation)))
+		}
+	}
+	warekiRegex = regexp.MustCompile(fmt.Sprintf(`(%s)(\d+|元)年(\d+)月(\d+)日`, strings.Join(names, "|")))
+	abbreviatedWarekiRegex = regexp.MustCompile(fmt.Sprintf(`^([%s])\.?\s*(\d+|元)[./\-年](\d{1,2})[./\-月](\d{1,2})日?$`, letters.String()))
+	eraAbbreviations = abbreviations
+}
+
+// Eras returns the known eras by start date.
+func Eras() []Era {
+	erasMu.RLock()
+	defer erasMu.RUnlock()
+	return append([]Era(nil), eras...)
+}
+
+// RegisterEra adds an era that starts after the last known era, e.g. when a new era is
+// announced. Dates from its start on are read and rendered in the new era, and dates of
+// the previous era from that day on become invalid.
+func RegisterEra(era Era) error {
+	if era.Name == "" || era.Start.IsZero() {
+		return fmt.Errorf("%w: name and start date are required", ErrInvalidEra)
+	}
+	if len(era.Abbreviation) > 1 {
+		return fmt.Errorf("%w: abbreviation %q is not a single letter", ErrInvalidEra, era.Abbreviation)
+	}
+	era.Start = date(era.Start.Year(), era.Start.Month(), era.Start.Day())
+
+	erasMu.Lock()
+	defer erasMu.Unlock()
+	last := eras[len(eras)-1]
+	if !era.Start.After(last.Start) {
+		return fmt.Errorf("%w: %s must start after %s (%s)", ErrInvalidEra, era.Name, last.Name, last.Start.Format("2006-01-02"))
+	}
+	for _, e := range eras {
+		if e.Name == era.Name {
+			return fmt.Errorf("%w: %s is already registered", ErrInvalidEra, era.Name)
+		}
+		if era.Abbreviation != "" && strings.EqualFold(e.Abbreviation, era.Abbreviation) {
+			return fmt.Errorf("%w: abbreviation %s is already used by %s", ErrInvalidEra, era.Abbreviation, e.Name)
+		}
+		if []rune(e.Name)[0] == []rune(era.Name)[0] {
+			return fmt.Errorf("%w: %s starts with the same character as %s", ErrInvalidEra, era.Name, e.Name)
+		}
+	}
+	eras = append(eras, era)
+	compileEras()
+	return nil
+}
+
+// warekiToTime converts a Japanese era date string to a time.Time object. The date must
+// fall within the era, e.g. 平成31年4月30日 is the last day of Heisei.
+func warekiToTime(warekiStr string) (time.Time, error) {
+	warekiStr = strings.TrimSpace(warekiStr)
+	// Replace "元年" (gan-nen) with "1年" for easier parsing.
+	warekiStr = strings.Replace(warekiStr, "元年", "1年", 1)
+
+	erasMu.RLock()
+	defer erasMu.RUnlock()
+
+	matches := warekiRegex.FindStringSubmatch(warekiStr)
+	if len(matches) != 5 {
+		return time.Time{}, fmt.Errorf("invalid wareki format: %s", warekiStr)
+	}
+
+	name := matches[1]
+	yearStr := matches[2]
+	monthStr := matches[3]
+	dayStr := matches[4]
+
+	year, err := strconv.Atoi(yearStr)
+	if err != nil || year < 1 {
+		return time.Time{}, fmt.Errorf("invalid year: %s", yearStr)
+	}
+
+	month, err := strconv.Atoi(monthStr)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid month: %s", monthStr)
+	}
+
+	day, err := strconv.Atoi(dayStr)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid day: %s", dayStr)
+	}
+
+	i := eraIndex(name)
+	if i < 0 {
+		return time.Time{}, fmt.Errorf("unknown era: %s", name)
+	}
+	era := eras[i]
+
+	gregorianYear := era.firstYear() + year - 1
+
+	// Let time.Parse validate the date's existence (e.g., Feb 30)
+	dateStr := fmt.Sprintf("%d-%02d-%02d", gregorianYear, month, day)
+	t, err := time.Parse("2006-01-02", dateStr)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("date validation failed: %w", err)
+	}
+
+	if t.Before(era.Start) {
+		return time.Time{}, fmt.Errorf("%s is before the start of %s (%s)", warekiStr, era.Name, era.Start.Format("2006-01-02"))
+	}
+	if i+1 < len(eras) && !t.Before(eras[i+1].Start) {
+		return time.Time{}, fmt.Errorf("%s is after the end of %s (%s)", warekiStr, era.Name, eras[i+1].Start.AddDate(0, 0, -1).Format("2006-01-02"))
+	}
+	return t, nil
+}
+
+// eraIndex returns the index of the era named name, or -1. erasMu must be held.
+func eraIndex(name string) int {
+	for i, e := range eras {
+		if e.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// ToWareki renders t as a Japanese era date such as 令和3年9月22日. The first year of
+// an era is written 元年, as in 令和元年5月1日. Dates before the first known era are an
+// error.
+func ToWareki(t time.Time) (string, error) {
+	day := date(t.Year(), t.Month(), t.Day())
+
+	erasMu.RLock()
+	defer erasMu.RUnlock()
+	for i := len(eras) - 1; i >= 0; i-- {
+		era := eras[i]
+		if day.Before(era.Start) {
+			continue
+		}
+		year := "元"
+		if n := t.Year() - era.firstYear() + 1; n > 1 {
+			year = strconv.Itoa(n)
+		}
+		return fmt.Sprintf("%s%s年%d月%d日", era.Name, year, int(t.Month()), t.Day()), nil
+	}
+	return "", fmt.Errorf("%s is before the first era", day.Format("2006-01-02"))
+}
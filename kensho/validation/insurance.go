@@ -0,0 +1,58 @@
+package validation
+
+import (
+	"context"
+	"fmt"
+)
+
+// ValidateInsurerNumber validates the check digit of the insurer number (保険者番号) of a
+// health insurance card. Insurer numbers have 8 digits: the law number (法別番号), the
+// prefecture number (都道府県番号), the insurer's own number (保険者別番号) and the check
+// digit (検証番号). National health insurance numbers have no law number and 6 digits.
+func ValidateInsurerNumber(number string) bool {
+	return insurerNumberValidator(context.Background(), FieldContext{Value: number}).Status == StatusValid
+}
+
+// insurerCheckDigit returns the check digit of the digits of an insurer number before
+// its check digit. Weighting them 2, 1, 2, ... from the right, the digits of the products
+// are summed and the check digit is the difference of the sum to the next multiple of 10.
+func insurerCheckDigit(digits string) int {
+	sum := 0
+	for i := 0; i < len(digits); i++ {
+		d := int(digits[len(digits)-1-i] - '0')
+		if i%2 == 0 {
+			d *= 2
+		}
+		sum += d/10 + d%10
+	}
+	return (10 - sum%10) % 10
+}
+
+// insurerNumberValidator checks the insurer number of a health insurance card and tells
+// which part of it is wrong.
+func insurerNumberValidator(ctx context.Context, field FieldContext) Result {
+	number := normalizeNumber(field.Value)
+	for _, r := range number {
+		if r < '0' || r > '9' {
+			return Invalid(fmt.Sprintf("contains a non-digit character %q", r))
+		}
+	}
+	if len(number) != 6 && len(number) != 8 {
+		return Invalid(fmt.Sprintf("has %d digits instead of 6 or 8", len(number)))
+	}
+
+	prefecture := number[len(number)-6 : len(number)-4]
+	if prefecture < "01" || prefecture > "47" {
+		return Invalid(fmt.Sprintf("unknown prefecture number %s", prefecture))
+	}
+	if int(number[len(number)-1]-'0') != insurerCheckDigit(number[:len(number)-1]) {
+		return Invalid("check digit mismatch")
+	}
+	return Valid()
+}
+
+// branchNumberValidator checks the branch number (枝番) of a health insurance card, 2 digits
+// identifying the insured person among the members of a household.
+func branchNumberValidator(ctx context.Context, field FieldContext) Result {
+	return checkDigits(normalizeNumber(field.Value), 2, anyDigits)
+}
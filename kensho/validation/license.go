@@ -0,0 +1,200 @@
+package validation
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/y-mitsuyoshi/kensho/kensho/address"
+)
+
+// DriverLicenseNumber is the decoded structure of the 12-digit number of a Japanese
+// driver's license.
+type DriverLicenseNumber struct {
+	// Number is the license number without separators.
+	Number string
+	// CommissionCode is the code of the Public Safety Commission (公安委員会) that first
+	// issued the license, in digits 1-2.
+	CommissionCode string
+	// Commission is the name of that Public Safety Commission, e.g. 東京都公安委員会.
+	Commission string
+	// Prefecture is the prefecture of that Public Safety Commission, e.g. 東京都.
+	Prefecture string
+	// AcquisitionYear is the year the holder first acquired a license, from the last two
+	// digits of the year in digits 3-4.
+	AcquisitionYear int
+	// Serial is the serial number assigned by the commission, in digits 5-10.
+	Serial string
+	// CheckDigit is digit 11, computed from digits 1-10 with modulus 11.
+	CheckDigit int
+	// ReissueCount is the number of times the license was reissued after loss or damage,
+	// in digit 12.
+	ReissueCount int
+}
+
+// licenseCommission is a Public Safety Commission issuing driver's licenses.
+type licenseCommission struct {
+	name       string
+	prefecture string
+}
+
+// licenseCommissions maps the codes of the Public Safety Commissions to their names.
+// Hokkaido has one commission per regional police headquarters.
+var licenseCommissions = map[string]licenseCommission{
+	"10": {"北海道札幌方面公安委員会", "北海道"},
+	"11": {"北海道函館方面公安委員会", "北海道"},
+	"12": {"北海道旭川方面公安委員会", "北海道"},
+	"13": {"北海道釧路方面公安委員会", "北海道"},
+	"14": {"北海道北見方面公安委員会", "北海道"},
+	"20": {"青森県公安委員会", "青森県"},
+	"21": {"岩手県公安委員会", "岩手県"},
+	"22": {"宮城県公安委員会", "宮城県"},
+	"23": {"秋田県公安委員会", "秋田県"},
+	"24": {"山形県公安委員会", "山形県"},
+	"25": {"福島県公安委員会", "福島県"},
+	"30": {"東京都公安委員会", "東京都"},
+	"40": {"茨城県公安委員会", "茨城県"},
+	"41": {"栃木県公安委員会", "栃木県"},
+	"42": {"群馬県公安委員会", "群馬県"},
+	"43": {"埼玉県公安委員会", "埼玉県"},
+	"44": {"千葉県公安委員会", "千葉県"},
+	"45": {"神奈川県公安委員会", "神奈川県"},
+	"46": {"新潟県公安委員会", "新潟県"},
+	"47": {"山梨県公安委員会", "山梨県"},
+	"48": {"長野県公安委員会", "長野県"},
+	"49": {"静岡県公安委員会", "静岡県"},
+	"50": {"富山県公安委員会", "富山県"},
+	"51": {"石川県公安委員会", "石川県"},
+	"52": {"福井県公安委員会", "福井県"},
+	"53": {"岐阜県公安委員会", "岐阜県"},
+	"54": {"愛知県公安委員会", "愛知県"},
+	"55": {"三重県公安委員会", "三重県"},
+	"60": {"滋賀県公安委員会", "滋賀県"},
+	"61": {"京都府公安委員会", "京都府"},
+	"62": {"大阪府公安委員会", "大阪府"},
+	"63": {"兵庫県公安委員会", "兵庫県"},
+	"64": {"奈良県公安委員会", "奈良県"},
+	"65": {"和歌山県公安委員会", "和歌山県"},
+	"70": {"鳥取県公安委員会", "鳥取県"},
+	"71": {"島根県公安委員会", "島根県"},
+	"72": {"岡山県公安委員会", "岡山県"},
+	"73": {"広島県公安委員会", "広島県"},
+	"74": {"山口県公安委員会", "山口県"},
+	"80": {"徳島県公安委員会", "徳島県"},
+	"81": {"香川県公安委員会", "香川県"},
+	"82": {"愛媛県公安委員会", "愛媛県"},
+	"83": {"高知県公安委員会", "高知県"},
+	"90": {"福岡県公安委員会", "福岡県"},
+	"91": {"佐賀県公安委員会", "佐賀県"},
+	"92": {"長崎県公安委員会", "長崎県"},
+	"93": {"熊本県公安委員会", "熊本県"},
+	"94": {"大分県公安委員会", "大分県"},
+	"95": {"宮崎県公安委員会", "宮崎県"},
+	"96": {"鹿児島県公安委員会", "鹿児島県"},
+	"97": {"沖縄県公安委員会", "沖縄県"},
+}
+
+// licenseCheckWeights are the weights of digits 1-10 in the check digit of a driver's
+// license number.
+var licenseCheckWeights = []int{5, 4, 3, 2, 7, 6, 5, 4, 3, 2}
+
+// licenseNumberReplacer removes the notations printed around a driver's license number.
+var licenseNumberReplacer = strings.NewReplacer(" ", "", "　", "", "第", "", "号", "")
+
+// ParseDriverLicenseNumber decodes the structure of a Japanese driver's license number such
+// as 第301512345650号. It returns an error when the number does not have 12 digits, the
+// commission code is unknown or the check digit does not match.
+func ParseDriverLicenseNumber(number string) (DriverLicenseNumber, error) {
+	return ParseDriverLicenseNumberAt(number, time.Now())
+}
+
+// ParseDriverLicenseNumberAt is like ParseDriverLicenseNumber but expands the acquisition
+// year relative to now instead of the current time.
+func ParseDriverLicenseNumberAt(number string, now time.Time) (DriverLicenseNumber, error) {
+	number = licenseNumberReplacer.Replace(fullWidthReplacer.Replace(number))
+	for _, r := range number {
+		if r < '0' || r > '9' {
+			return DriverLicenseNumber{}, fmt.Errorf("contains a non-digit character %q", r)
+		}
+	}
+	if len(number) != 12 {
+		return DriverLicenseNumber{}, fmt.Errorf("has %d digits instead of %d", len(number), 12)
+	}
+
+	code := number[:2]
+	commission, ok := licenseCommissions[code]
+	if !ok {
+		return DriverLicenseNumber{}, fmt.Errorf("unknown public safety commission code %s", code)
+	}
+
+	sum := 0
+	for i, w := range licenseCheckWeights {
+		sum += int(number[i]-'0') * w
+	}
+	checkDigit := 0
+	if r := sum % 11; r > 1 {
+		checkDigit = 11 - r
+	}
+	if int(number[10]-'0') != checkDigit {
+		return DriverLicenseNumber{}, fmt.Errorf("check digit mismatch")
+	}
+
+	year, _ := strconv.Atoi(number[2:4])
+	return DriverLicenseNumber{
+		Number:          number,
+		CommissionCode:  code,
+		Commission:      commission.name,
+		Prefecture:      commission.prefecture,
+		AcquisitionYear: expandYear(year, now.Year()),
+		Serial:          number[4:10],
+		CheckDigit:      checkDigit,
+		ReissueCount:    int(number[11] - '0'),
+	}, nil
+}
+
+// expandYear returns the latest year up to current whose last two digits are yy.
+func expandYear(yy, current int) int {
+	year := current - current%100 + yy
+	if year > current {
+		year -= 100
+	}
+	return year
+}
+
+// driverLicenseNumberValidator checks a driver's license number and tells which part of
+// it is wrong.
+func driverLicenseNumberValidator(ctx context.Context, field FieldContext) Result {
+	if _, err := ParseDriverLicenseNumberAt(field.Value, field.now()); err != nil {
+		return Invalid(err.Error())
+	}
+	return Valid()
+}
+
+// licensePrefectureRule checks that the address is in the prefecture of the commission
+// that issued the driver's license. Its operands are the license number and the address.
+// A mismatch is a warning since the number is kept when the holder moves to another
+// prefecture. Addresses without a known prefecture are skipped.
+func licensePrefectureRule(ctx context.Context, doc DocumentContext) Result {
+	number, ok := doc.operand(0)
+	if !ok {
+		return Skipped()
+	}
+	value, ok := doc.operand(1)
+	if !ok {
+		return Skipped()
+	}
+	license, err := ParseDriverLicenseNumberAt(number, doc.now())
+	if err != nil {
+		return Skipped()
+	}
+	a, err := address.Parse(value)
+	if err != nil || a.Prefecture == "" {
+		return Skipped()
+	}
+	if a.Prefecture != license.Prefecture {
+		return Warning(fmt.Sprintf("%s is in %s but the license was issued by %s", doc.Operands[1], a.Prefecture, license.Commission))
+	}
+	return Valid()
+}
@@ -0,0 +1,274 @@
+package validation
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// mrzLineLength is the number of characters of each line of a TD3 machine readable zone.
+const mrzLineLength = 44
+
+// MRZ is the decoded machine readable zone of a passport in the TD3 format of ICAO 9303.
+type MRZ struct {
+	// DocumentCode is P for passports, possibly followed by a type letter.
+	DocumentCode string
+	// IssuingState is the ICAO code of the issuing state, e.g. JPN.
+	IssuingState string
+	// Surname is the primary identifier of the holder, with fillers replaced by spaces.
+	Surname string
+	// GivenNames is the secondary identifier of the holder, with fillers replaced by spaces.
+	GivenNames string
+	// PassportNumber is the document number without fillers.
+	PassportNumber string
+	// Nationality is the ICAO code of the nationality of the holder.
+	Nationality string
+	BirthDate   time.Time
+	// Sex is M, F or X for unspecified.
+	Sex        string
+	ExpiryDate time.Time
+	// PersonalNumber is the optional personal number without fillers.
+	PersonalNumber string
+}
+
+// mrzReplacer normalizes the characters of MRZ lines read by a model.
+var mrzReplacer = strings.NewReplacer(" ", "", "　", "", "＜", "<", "«", "<", "‹", "<")
+
+// MRZCheckDigit returns the ICAO 9303 check digit of s: the sum of its characters weighted
+// 7, 3, 1 in turn modulo 10, where digits count as their value, letters A to Z as 10 to 35
+// and the filler < as 0.
+func MRZCheckDigit(s string) int {
+	weights := [3]int{7, 3, 1}
+	sum := 0
+	for i, r := range s {
+		var v int
+		switch {
+		case r >= '0' && r <= '9':
+			v = int(r - '0')
+		case r >= 'A' && r <= 'Z':
+			v = int(r-'A') + 10
+		}
+		sum += v * weights[i%3]
+	}
+	return sum % 10
+}
+
+// ParseMRZ decodes the two lines of the machine readable zone of a passport in the TD3
+// format and verifies its check digits. Spaces are ignored and missing fillers at the end
+// of the first line are restored. The error tells which part of the zone is wrong.
+func ParseMRZ(line1, line2 string) (MRZ, error) {
+	return ParseMRZAt(line1, line2, time.Now())
+}
+
+// ParseMRZAt is like ParseMRZ but expands the two-digit years of the dates relative to now
+// instead of the current time.
+func ParseMRZAt(line1, line2 string, now time.Time) (MRZ, error) {
+	line1 = strings.ToUpper(mrzReplacer.Replace(fullWidthReplacer.Replace(line1)))
+	line2 = strings.ToUpper(mrzReplacer.Replace(fullWidthReplacer.Replace(line2)))
+	if len(line1) < mrzLineLength {
+		line1 += strings.Repeat("<", mrzLineLength-len(line1))
+	}
+	for i, line := range []string{line1, line2} {
+		if len(line) != mrzLineLength {
+			return MRZ{}, fmt.Errorf("line %d has %d characters instead of %d", i+1, len(line), mrzLineLength)
+		}
+		for _, r := range line {
+			if !(r >= '0' && r <= '9' || r >= 'A' && r <= 'Z' || r == '<') {
+				return MRZ{}, fmt.Errorf("line %d contains an invalid character %q", i+1, r)
+			}
+		}
+	}
+	if line1[0] != 'P' {
+		return MRZ{}, fmt.Errorf("document code %s is not a passport", strings.TrimRight(line1[:2], "<"))
+	}
+
+	checks := []struct {
+		name  string
+		data  string
+		digit byte
+	}{
+		{"passport number", line2[0:9], line2[9]},
+		{"birth date", line2[13:19], line2[19]},
+		{"expiry date", line2[21:27], line2[27]},
+		{"personal number", line2[28:42], line2[42]},
+		{"composite", line2[0:10] + line2[13:20] + line2[21:43], line2[43]},
+	}
+	for _, c := range checks {
+		if c.digit == '<' && strings.Trim(c.data, "<") == "" {
+			continue
+		}
+		if int(c.digit-'0') != MRZCheckDigit(c.data) {
+			return MRZ{}, fmt.Errorf("%s check digit mismatch", c.name)
+		}
+	}
+
+	birth, err := mrzDate(line2[13:19], now.Year())
+	if err != nil {
+		return MRZ{}, fmt.Errorf("invalid birth date: %w", err)
+	}
+	// Passports are valid for up to ten years.
+	expiry, err := mrzDate(line2[21:27], now.Year()+10)
+	if err != nil {
+		return MRZ{}, fmt.Errorf("invalid expiry date: %w", err)
+	}
+
+	surname, given, _ := strings.Cut(line1[5:], "<<")
+	sex := line2[20:21]
+	if sex == "<" {
+		sex = "X"
+	}
+	return MRZ{
+		DocumentCode:   strings.TrimRight(line1[0:2], "<"),
+		IssuingState:   strings.TrimRight(line1[2:5], "<"),
+		Surname:        mrzName(surname),
+		GivenNames:     mrzName(given),
+		PassportNumber: strings.TrimRight(line2[0:9], "<"),
+		Nationality:    strings.TrimRight(line2[10:13], "<"),
+		BirthDate:      birth,
+		Sex:            sex,
+		ExpiryDate:     expiry,
+		PersonalNumber: strings.TrimRight(line2[28:42], "<"),
+	}, nil
+}
+
+// mrzDate parses a YYMMDD date of the MRZ, in the latest century that does not put it
+// after the year latest.
+func mrzDate(s string, latest int) (time.Time, error) {
+	yy, err := strconv.Atoi(s[0:2])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s is not a date", s)
+	}
+	t, err := time.Parse("2006-0102", fmt.Sprintf("%d-%s", expandYear(yy, latest), s[2:]))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s is not a date", s)
+	}
+	return t, nil
+}
+
+// mrzName replaces the fillers of a name of the MRZ with spaces.
+func mrzName(s string) string {
+	return strings.Join(strings.Fields(strings.ReplaceAll(s, "<", " ")), " ")
+}
+
+// mrzRule checks the check digits of the machine readable zone of a passport. Its operands
+// are the two lines of the zone.
+func mrzRule(ctx context.Context, doc DocumentContext) Result {
+	line1, ok := doc.operand(0)
+	if !ok {
+		return Skipped()
+	}
+	line2, ok := doc.operand(1)
+	if !ok {
+		return Skipped()
+	}
+	if _, err := ParseMRZAt(line1, line2, doc.now()); err != nil {
+		return Invalid(err.Error())
+	}
+	return Valid()
+}
+
+// mrzMatchRule checks the fields of the visual zone of a passport against its machine
+// readable zone. Its operands are the two lines of the zone followed by the name, birth
+// date, sex, expiry date and passport number of the visual zone, any of which may be
+// left out. Names in other scripts than the Latin alphabet are not compared.
+func mrzMatchRule(ctx context.Context, doc DocumentContext) Result {
+	line1, ok := doc.operand(0)
+	if !ok {
+		return Skipped()
+	}
+	line2, ok := doc.operand(1)
+	if !ok {
+		return Skipped()
+	}
+	mrz, err := ParseMRZAt(line1, line2, doc.now())
+	if err != nil {
+		return Skipped()
+	}
+
+	var mismatches []string
+	compared := false
+	compare := func(i int, equal func(string) (bool, bool)) {
+		v, ok := doc.operand(i)
+		if !ok {
+			return
+		}
+		eq, comparable := equal(v)
+		if !comparable {
+			return
+		}
+		compared = true
+		if !eq {
+			mismatches = append(mismatches, doc.Operands[i]+" does not match the MRZ")
+		}
+	}
+	sameDate := func(want time.Time) func(string) (bool, bool) {
+		return func(v string) (bool, bool) {
+			t, err := ParseJapaneseDate(v)
+			return err == nil && t.Equal(want), err == nil
+		}
+	}
+
+	compare(2, func(v string) (bool, bool) {
+		tokens := nameTokens(v)
+		for _, t := range tokens {
+			for _, r := range t {
+				if r < 'A' || r > 'Z' {
+					return false, false
+				}
+			}
+		}
+		return equalTokens(tokens, nameTokens(mrz.Surname+" "+mrz.GivenNames)), len(tokens) > 0
+	})
+	compare(3, sameDate(mrz.BirthDate))
+	compare(4, func(v string) (bool, bool) {
+		sex, ok := sexCodes[strings.ToUpper(strings.TrimSpace(v))]
+		return sex == mrz.Sex, ok
+	})
+	compare(5, sameDate(mrz.ExpiryDate))
+	compare(6, func(v string) (bool, bool) {
+		return strings.ToUpper(mrzReplacer.Replace(fullWidthReplacer.Replace(v))) == mrz.PassportNumber, true
+	})
+
+	if len(mismatches) > 0 {
+		return Invalid(strings.Join(mismatches, "; "))
+	}
+	if !compared {
+		return Skipped()
+	}
+	return Valid()
+}
+
+// sexCodes maps the notations of sex in the visual zone to the codes of the MRZ.
+var sexCodes = map[string]string{
+	"M": "M", "MALE": "M", "男": "M", "男性": "M",
+	"F": "F", "FEMALE": "F", "女": "F", "女性": "F",
+	"X": "X", "<": "X",
+}
+
+// nameTokens splits a name into its upper-case parts.
+func nameTokens(s string) []string {
+	return strings.FieldsFunc(strings.ToUpper(s), func(r rune) bool {
+		return r == ' ' || r == '　' || r == ',' || r == '<' || r == '/'
+	})
+}
+
+// equalTokens reports whether a and b hold the same parts in any order, so that names
+// written given name first match the surname first order of the MRZ.
+func equalTokens(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a = append([]string(nil), a...)
+	b = append([]string(nil), b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,263 @@
+package validation
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Status is the outcome of a validator.
+type Status string
+
+const (
+	// StatusValid means that the value passed the validator.
+	StatusValid Status = "valid"
+	// StatusInvalid means that the value failed the validator.
+	StatusInvalid Status = "invalid"
+	// StatusSkipped means that the validator does not apply to the value.
+	StatusSkipped Status = "skipped"
+)
+
+// Severity tells how serious a failed validation is.
+type Severity string
+
+const (
+	// SeverityError marks a value that must not be trusted.
+	SeverityError Severity = "error"
+	// SeverityWarning marks a value that should be reviewed.
+	SeverityWarning Severity = "warning"
+)
+
+// FieldContext is the field passed to a validator.
+type FieldContext struct {
+	// DocumentType is the document type the field was extracted from.
+	DocumentType string
+	// Name is the name of the field in the JSON structure.
+	Name string
+	// Value is the extracted value.
+	Value string
+	// Fields holds the string values of all the fields of the document, by name.
+	Fields map[string]string
+	// Now is the time the field is validated at. The zero value means time.Now.
+	Now time.Time
+}
+
+// now returns Now, or the current time when it is zero.
+func (f FieldContext) now() time.Time {
+	if f.Now.IsZero() {
+		return time.Now()
+	}
+	return f.Now
+}
+
+// today returns the date of Now.
+func (f FieldContext) today() time.Time {
+	now := f.now()
+	return date(now.Year(), now.Month(), now.Day())
+}
+
+// Result is the outcome of a validator and the reason for it.
+type Result struct {
+	Status Status
+	Reason string
+	// Severity of an invalid result. Empty means SeverityError.
+	Severity Severity
+}
+
+// Valid returns a valid result.
+func Valid() Result {
+	return Result{Status: StatusValid}
+}
+
+// Invalid returns an invalid result with the given reason.
+func Invalid(reason string) Result {
+	return Result{Status: StatusInvalid, Reason: reason}
+}
+
+// Warning returns an invalid result of warning severity with the given reason.
+func Warning(reason string) Result {
+	return Result{Status: StatusInvalid, Reason: reason, Severity: SeverityWarning}
+}
+
+// Validator checks the value of a field.
+type Validator func(ctx context.Context, field FieldContext) Result
+
+// Predicate adapts a function reporting whether a value is valid to a Validator that
+// fails with reason.
+func Predicate(fn func(value string) bool, reason string) Validator {
+	return func(ctx context.Context, field FieldContext) Result {
+		if fn(field.Value) {
+			return Valid()
+		}
+		return Invalid(reason)
+	}
+}
+
+// Registry maps names to validators and cross-field rules. It is safe for concurrent use.
+type Registry struct {
+	mu         sync.RWMutex
+	validators map[string]Validator
+	rules      map[string]Rule
+}
+
+// NewRegistry returns a registry holding the built-in validators and rules.
+func NewRegistry() *Registry {
+	return &Registry{
+		validators: map[string]Validator{
+			"date":                              Predicate(ValidateDate, "not a valid date"),
+			"driver_license_number":             driverLicenseNumberValidator,
+			"mynumber_checkdigit":               myNumberValidator,
+			"not_expired":                       notExpiredValidator,
+			"residence_card_number":             residenceCardNumberValidator,
+			"period_of_stay":                    periodOfStayValidator,
+			"work_restrictions":                 workRestrictionsValidator,
+			"special_permanent_resident_number": residenceCardNumberValidator,
+			"insurer_number":                    insurerNumberValidator,
+			"branch_number":                     branchNumberValidator,
+			"corporate_number":                  corporateNumberValidator,
+			"company_registration_number":       companyRegistrationNumberValidator,
+			"invoice_registration_number":       invoiceRegistrationNumberValidator,
+			"basic_pension_number":              basicPensionNumberValidator,
+			"address":                           addressValidator,
+		},
+		rules: map[string]Rule{
+			"date_order":              dateOrderRule,
+			"license_age":             licenseAgeRule,
+			"license_expiry_birthday": licenseExpiryBirthdayRule,
+			"address_match":           addressMatchRule,
+			"license_prefecture":      licensePrefectureRule,
+			"mrz":                     mrzRule,
+			"mrz_match":               mrzMatchRule,
+		},
+	}
+}
+
+// Register makes a validator available under name, replacing any validator with the
+// same name. It panics if v is nil.
+func (r *Registry) Register(name string, v Validator) {
+	if v == nil {
+		panic("validation: Register validator is nil")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.validators[name] = v
+}
+
+// Lookup returns the validator registered under name.
+func (r *Registry) Lookup(name string) (Validator, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	v, ok := r.validators[name]
+	return v, ok
+}
+
+// Names returns the names of the registered validators in alphabetical order.
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.validators))
+	for name := range r.validators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RegisterRule makes a cross-field rule available under name, replacing any rule with the
+// same name. It panics if rule is nil.
+func (r *Registry) RegisterRule(name string, rule Rule) {
+	if rule == nil {
+		panic("validation: RegisterRule rule is nil")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rules[name] = rule
+}
+
+// LookupRule returns the cross-field rule registered under name.
+func (r *Registry) LookupRule(name string) (Rule, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	rule, ok := r.rules[name]
+	return rule, ok
+}
+
+// RuleNames returns the names of the registered cross-field rules in alphabetical order.
+func (r *Registry) RuleNames() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.rules))
+	for name := range r.rules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// DefaultRegistry is the registry used by clients unless another one is configured.
+var DefaultRegistry = NewRegistry()
+
+// Register makes a validator available under name in DefaultRegistry.
+func Register(name string, v Validator) {
+	DefaultRegistry.Register(name, v)
+}
+
+// RegisterRule makes a cross-field rule available under name in DefaultRegistry.
+func RegisterRule(name string, rule Rule) {
+	DefaultRegistry.RegisterRule(name, rule)
+}
+
+// Lookup returns the validator registered under name in DefaultRegistry.
+func Lookup(name string) (Validator, bool) {
+	return DefaultRegistry.Lookup(name)
+}
+
+// LookupRule returns the cross-field rule registered under name in DefaultRegistry.
+func LookupRule(name string) (Rule, bool) {
+	return DefaultRegistry.LookupRule(name)
+}
+
+// Names returns the names of the validators of DefaultRegistry in alphabetical order.
+func Names() []string {
+	return DefaultRegistry.Names()
+}
+
+// myNumberValidator checks an Individual Number and tells which part of it is wrong.
+func myNumberValidator(ctx context.Context, field FieldContext) Result {
+	number := strings.ReplaceAll(field.Value, "-", "")
+	return checkDigits(number, 12, ValidateMyNumber)
+}
+
+// notExpiredValidator checks that the expiry date in the field has not passed. A document
+// is valid through its expiry date. Values that are not dates are left to the date
+// validator.
+func notExpiredValidator(ctx context.Context, field FieldContext) Result {
+	expiry, err := ParseJapaneseDate(field.Value)
+	if err != nil {
+		return Skipped()
+	}
+	if expiry.Before(field.today()) {
+		return Invalid(fmt.Sprintf("expired on %s", expiry.Format("2006-01-02")))
+	}
+	return Valid()
+}
+
+// checkDigits checks that number has the given number of digits before checking it with
+// validate, which is expected to verify its check digit.
+func checkDigits(number string, length int, validate func(string) bool) Result {
+	for _, r := range number {
+		if r < '0' || r > '9' {
+			return Invalid(fmt.Sprintf("contains a non-digit character %q", r))
+		}
+	}
+	if len(number) != length {
+		return Invalid(fmt.Sprintf("has %d digits instead of %d", len(number), length))
+	}
+	if !validate(number) {
+		return Invalid("check digit mismatch")
+	}
+	return Valid()
+}
@@ -0,0 +1,102 @@
+package validation
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// residenceCardNumberRegex matches the number of a residence card or of a special permanent
+// resident certificate: 2 letters, 8 digits and 2 letters, e.g. AB12345678CD.
+var residenceCardNumberRegex = regexp.MustCompile(`^[A-Z]{2}[0-9]{8}[A-Z]{2}$`)
+
+// residenceCardNumberLength is the number of characters of a residence card number.
+const residenceCardNumberLength = 12
+
+// normalizeResidenceCardNumber removes spaces and converts full-width characters and
+// lower-case letters of a residence card number.
+func normalizeResidenceCardNumber(number string) string {
+	number = strings.Map(func(r rune) rune {
+		switch {
+		case r == ' ' || r == '　' || r == '-':
+			return -1
+		case r >= 'Ａ' && r <= 'Ｚ':
+			return r - 'Ａ' + 'A'
+		case r >= 'ａ' && r <= 'ｚ':
+			return r - 'ａ' + 'a'
+		}
+		return r
+	}, fullWidthReplacer.Replace(number))
+	return strings.ToUpper(number)
+}
+
+// ValidateResidenceCardNumber validates the format of the number of a residence card
+// (在留カード) or of a special permanent resident certificate (特別永住者証明書), 2 letters
+// followed by 8 digits and 2 letters.
+func ValidateResidenceCardNumber(number string) bool {
+	return residenceCardNumberRegex.MatchString(normalizeResidenceCardNumber(number))
+}
+
+// residenceCardNumberValidator checks the number of a residence card or of a special
+// permanent resident certificate and tells which part of it is wrong.
+func residenceCardNumberValidator(ctx context.Context, field FieldContext) Result {
+	number := normalizeResidenceCardNumber(field.Value)
+	if n := len([]rune(number)); n != residenceCardNumberLength {
+		return Invalid(fmt.Sprintf("has %d characters instead of %d", n, residenceCardNumberLength))
+	}
+	if !residenceCardNumberRegex.MatchString(number) {
+		return Invalid("is not 2 letters, 8 digits and 2 letters")
+	}
+	return Valid()
+}
+
+// periodsOfStay lists the periods of stay granted by the Immigration Control Act, as
+// printed on residence cards.
+var periodsOfStay = map[string]bool{
+	"5年": true, "4年3月": true, "4年": true, "3年3月": true, "3年": true,
+	"2年3月": true, "2年": true, "1年3月": true, "1年": true,
+	"6月": true, "4月": true, "3月": true,
+	"90日": true, "30日": true, "15日": true, "15日以内": true,
+	"無期限": true, "法務大臣が個々に指定する期間": true,
+}
+
+// parentheticalRegex matches a part in parentheses, such as the expiry date printed after
+// the period of stay.
+var parentheticalRegex = regexp.MustCompile(`[(（].*?[)）]`)
+
+// periodOfStayValidator checks that the field holds a period of stay, such as 5年 or
+// 無期限. The expiry date printed after it in parentheses is ignored.
+func periodOfStayValidator(ctx context.Context, field FieldContext) Result {
+	period := parentheticalRegex.ReplaceAllString(field.Value, "")
+	period = strings.Join(strings.Fields(fullWidthReplacer.Replace(period)), "")
+	if period == "" {
+		return Skipped()
+	}
+	if !periodsOfStay[period] {
+		return Invalid(fmt.Sprintf("%q is not a period of stay", period))
+	}
+	return Valid()
+}
+
+// workRestrictions lists the work restrictions printed on residence cards.
+var workRestrictions = map[string]bool{
+	"就労制限なし": true,
+	"就労不可":   true,
+	"在留資格に基づく就労活動のみ可":          true,
+	"指定書記載機関での在留資格に基づく就労活動のみ可": true,
+	"指定書により指定された就労活動のみ可":       true,
+}
+
+// workRestrictionsValidator checks that the field holds one of the work restrictions
+// printed on residence cards.
+func workRestrictionsValidator(ctx context.Context, field FieldContext) Result {
+	restriction := strings.Join(strings.Fields(field.Value), "")
+	if restriction == "" {
+		return Skipped()
+	}
+	if !workRestrictions[restriction] {
+		return Invalid(fmt.Sprintf("%q is not a work restriction", restriction))
+	}
+	return Valid()
+}
@@ -0,0 +1,236 @@
+package validation
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/y-mitsuyoshi/kensho/kensho/address"
+)
+
+// DocumentContext is the document passed to a cross-field rule.
+type DocumentContext struct {
+	// DocumentType is the document type the fields were extracted from.
+	DocumentType string
+	// Fields holds the string values of the fields of the document, by name.
+	Fields map[string]string
+	// Operands lists the fields the rule is configured with, in order.
+	Operands []string
+	// Now is the time the document is validated at. The zero value means time.Now.
+	Now time.Time
+}
+
+// now returns Now, or the current time when it is zero.
+func (d DocumentContext) now() time.Time {
+	if d.Now.IsZero() {
+		return time.Now()
+	}
+	return d.Now
+}
+
+// operand returns the value of the i-th operand, if it was extracted.
+func (d DocumentContext) operand(i int) (string, bool) {
+	if i >= len(d.Operands) {
+		return "", false
+	}
+	v, ok := d.Fields[d.Operands[i]]
+	return v, ok && strings.TrimSpace(v) != ""
+}
+
+// operandDate returns the date of the i-th operand, if it was extracted and is a valid date.
+func (d DocumentContext) operandDate(i int) (time.Time, bool) {
+	v, ok := d.operand(i)
+	if !ok {
+		return time.Time{}, false
+	}
+	t, err := ParseJapaneseDate(v)
+	return t, err == nil
+}
+
+// Rule checks the consistency of several fields of a document.
+type Rule func(ctx context.Context, doc DocumentContext) Result
+
+// Skipped returns a skipped result, for rules whose fields were not extracted.
+func Skipped() Result {
+	return Result{Status: StatusSkipped}
+}
+
+// dateOrderRule checks that the dates of its operands are strictly increasing, e.g.
+// birth_date < issue_date < expiry_date. Missing and invalid dates are ignored.
+func dateOrderRule(ctx context.Context, doc DocumentContext) Result {
+	var (
+		prev     time.Time
+		prevName string
+		compared bool
+	)
+	for i, name := range doc.Operands {
+		t, ok := doc.operandDate(i)
+		if !ok {
+			continue
+		}
+		if prevName != "" {
+			if !t.After(prev) {
+				return Invalid(fmt.Sprintf("%s (%s) is not after %s (%s)", name, t.Format("2006-01-02"), prevName, prev.Format("2006-01-02")))
+			}
+			compared = true
+		}
+		prev, prevName = t, name
+	}
+	if !compared {
+		return Skipped()
+	}
+	return Valid()
+}
+
+// licenseMinimumAges is the minimum age for each driver's license class under the Road
+// Traffic Act, by the names and abbreviations printed on the license. Large, medium and
+// second-class licenses can be obtained at 19 with the special training introduced in 2022.
+var licenseMinimumAges = map[string]int{
+	"大型特殊二種": 19, "大特二": 19,
+	"けん引二種": 19, "け引二": 19,
+	"大型二種": 19, "大二": 19,
+	"中型二種": 19, "中二": 19,
+	"普通二種": 19, "普二": 19,
+	"大型二輪": 18, "大自二": 18,
+	"普通二輪": 16, "普自二": 16,
+	"大型特殊": 18, "大特": 18,
+	"小型特殊": 16, "小特": 16,
+	"準中型": 18, "準中": 18,
+	"大型":  19,
+	"中型":  19,
+	"普通":  18,
+	"けん引": 18, "け引": 18,
+	"原付": 16,
+}
+
+// lowestLicenseAge is the minimum age for any driver's license.
+const lowestLicenseAge = 16
+
+// maxPlausibleAge is the age above which a birth date is considered misread.
+const maxPlausibleAge = 120
+
+// licenseClasses returns the license classes found in s, longest names first so that
+// 大型二輪 is not also read as 大型.
+func licenseClasses(s string) []string {
+	names := make([]string, 0, len(licenseMinimumAges))
+	for name := range licenseMinimumAges {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if len([]rune(names[i])) != len([]rune(names[j])) {
+			return len([]rune(names[i])) > len([]rune(names[j]))
+		}
+		return names[i] < names[j]
+	})
+
+	var classes []string
+	for _, name := range names {
+		if strings.Contains(s, name) {
+			classes = append(classes, name)
+			s = strings.ReplaceAll(s, name, " ")
+		}
+	}
+	return classes
+}
+
+// age returns the age in full years at on of a person born on birth.
+func age(birth, on time.Time) int {
+	years := on.Year() - birth.Year()
+	if on.Month() < birth.Month() || on.Month() == birth.Month() && on.Day() < birth.Day() {
+		years--
+	}
+	return years
+}
+
+// licenseAgeRule checks that the holder was old enough for the license classes at the
+// issue date. Its operands are the birth date, the issue date and, optionally, the license
+// classes; without classes the lowest minimum age of any license applies.
+func licenseAgeRule(ctx context.Context, doc DocumentContext) Result {
+	birth, ok := doc.operandDate(0)
+	if !ok {
+		return Skipped()
+	}
+	issued, ok := doc.operandDate(1)
+	if !ok {
+		return Skipped()
+	}
+
+	minAge, class := lowestLicenseAge, ""
+	if types, ok := doc.operand(2); ok {
+		for _, c := range licenseClasses(types) {
+			if licenseMinimumAges[c] > minAge {
+				minAge, class = licenseMinimumAges[c], c
+			}
+		}
+	}
+
+	a := age(birth, issued)
+	switch {
+	case a < minAge && class != "":
+		return Invalid(fmt.Sprintf("holder was %d at issue, below the minimum age of %d for %s", a, minAge, class))
+	case a < minAge:
+		return Invalid(fmt.Sprintf("holder was %d at issue, below the minimum age of %d for a driver's license", a, minAge))
+	case a > maxPlausibleAge:
+		return Invalid(fmt.Sprintf("holder was %d at issue, which is implausible", a))
+	}
+	return Valid()
+}
+
+// licenseExpiryBirthdayRule checks that a driver's license expires one month after a
+// birthday of the holder, the one in the month before expiry, so that a December birthday
+// leads to a January expiry. Its operands are the birth date and the expiry date.
+func licenseExpiryBirthdayRule(ctx context.Context, doc DocumentContext) Result {
+	birth, ok := doc.operandDate(0)
+	if !ok {
+		return Skipped()
+	}
+	expiry, ok := doc.operandDate(1)
+	if !ok {
+		return Skipped()
+	}
+
+	year := expiry.AddDate(0, -1, 0).Year()
+	expected := addMonthClamped(year, birth.Month(), birth.Day())
+	if !expiry.Equal(expected) {
+		return Invalid(fmt.Sprintf("expiry date %s is not one month after the birthday (%s)", expiry.Format("2006-01-02"), expected.Format("2006-01-02")))
+	}
+	return Valid()
+}
+
+// addMonthClamped returns the day one month after the given date, clamped to the end of
+// the month, e.g. March 31 becomes April 30.
+func addMonthClamped(year int, month time.Month, day int) time.Time {
+	firstOfNext := time.Date(year, month+1, 1, 0, 0, 0, 0, time.UTC)
+	lastDay := firstOfNext.AddDate(0, 1, -1).Day()
+	if day > lastDay {
+		day = lastDay
+	}
+	return time.Date(firstOfNext.Year(), firstOfNext.Month(), day, 0, 0, 0, 0, time.UTC)
+}
+
+// addressMatchRule checks that its operands hold the same address, e.g. the address on
+// the front of a card and the one written on its back. A mismatch is a warning since it
+// usually records a move.
+func addressMatchRule(ctx context.Context, doc DocumentContext) Result {
+	first, ok := doc.operand(0)
+	if !ok {
+		return Skipped()
+	}
+	compared := false
+	for i := 1; i < len(doc.Operands); i++ {
+		v, ok := doc.operand(i)
+		if !ok {
+			continue
+		}
+		if address.Normalize(v) != address.Normalize(first) {
+			return Warning(fmt.Sprintf("%s differs from %s", doc.Operands[i], doc.Operands[0]))
+		}
+		compared = true
+	}
+	if !compared {
+		return Skipped()
+	}
+	return Valid()
+}
@@ -1,6 +1,12 @@
 package validation
 
-import "testing"
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
 
 func TestValidateDriverLicenseNumber(t *testing.T) {
 	testCases := []struct {
@@ -8,9 +14,12 @@ func TestValidateDriverLicenseNumber(t *testing.T) {
 		number   string
 		expected bool
 	}{
-		// Based on the dummy weights in the function, for "12345678901", the check digit is 2.
-		{"valid", "123456789012", true},
-		{"invalid check digit", "123456789013", false},
+		// Tokyo (30), first acquired in 2015, serial 123456, check digit 5, not reissued.
+		{"valid", "301512345650", true},
+		{"valid with notation", "第301512345650号", true},
+		{"valid reissued", "301512345651", true},
+		{"invalid check digit", "301512345640", false},
+		{"unknown commission", "991512345650", false},
 		{"invalid length", "12345", false},
 		{"invalid char", "12345678901a", false},
 		{"empty", "", false},
@@ -58,10 +67,11 @@ func TestValidateDate(t *testing.T) {
 		{"valid date with kanji", "2023年1月15日", true},
 		{"valid date with space", " 2024年12月1日 ", true},
 		{"valid YYYY-MM-DD", "2023-01-15", true},
+		{"valid YYYY/MM/DD", "2023/01/15", true},
 
 		// Invalid Gregorian Dates
 		{"invalid date", "2023年2月30日", false},
-		{"invalid format", "2023/01/15", false},
+		{"invalid format", "15/01/2023", false},
 		{"empty", "", false},
 
 		// Valid Japanese Era Dates
@@ -72,12 +82,23 @@ func TestValidateDate(t *testing.T) {
 		{"user birth_date", "平成2年10月8日", true},
 		{"user issue_date", "令和03年09月22日", true},
 		{"user expiry_date", "令和08年11月08日", true},
-		{"leading zero in day", "平成元年1月01日", true},
+		{"leading zero in day", "平成元年1月08日", true},
+		{"first day of showa", "昭和元年12月25日", true},
+		{"last day of showa", "昭和64年1月7日", true},
+		{"last day of heisei", "平成31年4月30日", true},
+		{"first day of reiwa", "令和元年5月1日", true},
 
 		// Invalid Japanese Era Dates
 		{"invalid era day", "令和10年2月30日", false},
 		{"invalid era month", "平成10年13月1日", false},
 		{"invalid era name", "試験10年1月1日", false},
+		{"before the start of showa", "昭和1年1月1日", false},
+		{"before the start of heisei", "平成元年1月1日", false},
+		{"before the start of reiwa", "令和1年4月1日", false},
+		{"after the end of heisei", "平成31年5月1日", false},
+		{"year after the end of heisei", "平成32年1月1日", false},
+		{"abbreviated after the end of heisei", "H32.1.1", false},
+		{"year zero", "令和0年5月1日", false},
 	}
 
 	for _, tc := range testCases {
@@ -88,3 +109,529 @@ func TestValidateDate(t *testing.T) {
 		})
 	}
 }
+
+func TestLookup(t *testing.T) {
+	for _, name := range []string{"date", "driver_license_number", "mynumber_checkdigit"} {
+		if _, ok := Lookup(name); !ok {
+			t.Errorf("expected validator %s to be registered", name)
+		}
+	}
+	if _, ok := Lookup("unknown"); ok {
+		t.Error("expected unknown validator not to be registered")
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register("always_invalid", func(ctx context.Context, field FieldContext) Result {
+		return Invalid("never valid")
+	})
+
+	v, ok := registry.Lookup("always_invalid")
+	if !ok {
+		t.Fatal("expected registered validator to be found")
+	}
+	if got := v(context.Background(), FieldContext{Value: "x"}); got.Status != StatusInvalid || got.Reason != "never valid" {
+		t.Errorf("unexpected result: %+v", got)
+	}
+	if _, ok := Lookup("always_invalid"); ok {
+		t.Error("expected validator not to leak into the default registry")
+	}
+
+	date, _ := registry.Lookup("date")
+	if got := date(context.Background(), FieldContext{Value: "令和3年9月22日"}); got.Status != StatusValid {
+		t.Errorf("expected valid date, but got %+v", got)
+	}
+	if got := date(context.Background(), FieldContext{Value: "令和3年2月30日"}); got.Status != StatusInvalid || got.Reason == "" {
+		t.Errorf("expected invalid date with reason, but got %+v", got)
+	}
+
+	myNumber, _ := registry.Lookup("mynumber_checkdigit")
+	reasons := map[string]string{
+		"123456789018":   "",
+		"1234-5678-9018": "",
+		"12345678901":    "has 11 digits instead of 12",
+		"12345678901X":   "contains a non-digit character 'X'",
+		"123456789012":   "check digit mismatch",
+	}
+	for number, reason := range reasons {
+		if got := myNumber(context.Background(), FieldContext{Value: number}); got.Reason != reason {
+			t.Errorf("expected reason %q for %s, but got %q", reason, number, got.Reason)
+		}
+	}
+}
+
+func TestRules(t *testing.T) {
+	fields := func(kv ...string) map[string]string {
+		m := make(map[string]string)
+		for i := 0; i+1 < len(kv); i += 2 {
+			m[kv[i]] = kv[i+1]
+		}
+		return m
+	}
+
+	testCases := []struct {
+		name     string
+		rule     string
+		fields   map[string]string
+		operands []string
+		expected Status
+	}{
+		{"dates in order", "date_order", fields("birth_date", "昭和60年1月1日", "issue_date", "平成25年4月1日", "expiry_date", "2018年2月1日"), []string{"birth_date", "issue_date", "expiry_date"}, StatusValid},
+		{"issue before birth", "date_order", fields("birth_date", "平成2年10月8日", "issue_date", "昭和60年1月1日"), []string{"birth_date", "issue_date", "expiry_date"}, StatusInvalid},
+		{"equal dates", "date_order", fields("issue_date", "令和3年9月22日", "expiry_date", "2021-09-22"), []string{"issue_date", "expiry_date"}, StatusInvalid},
+		{"single date", "date_order", fields("birth_date", "昭和60年1月1日"), []string{"birth_date", "issue_date"}, StatusSkipped},
+
+		{"old enough for motorcycle", "license_age", fields("birth", "2000年5月1日", "issued", "2016年5月1日", "types", "普自二"), []string{"birth", "issued", "types"}, StatusValid},
+		{"too young for ordinary car", "license_age", fields("birth", "2000年5月2日", "issued", "2018年5月1日", "types", "普通 原付"), []string{"birth", "issued", "types"}, StatusInvalid},
+		{"large motorcycle is not large vehicle", "license_age", fields("birth", "2000年1月1日", "issued", "2018年6月1日", "types", "普通 大型二輪"), []string{"birth", "issued", "types"}, StatusValid},
+		{"too young for large vehicle", "license_age", fields("birth", "2000年1月1日", "issued", "2018年6月1日", "types", "大型"), []string{"birth", "issued", "types"}, StatusInvalid},
+		{"implausible age", "license_age", fields("birth", "1850年1月1日", "issued", "2018年6月1日"), []string{"birth", "issued", "types"}, StatusInvalid},
+
+		{"expiry one month after birthday", "license_expiry_birthday", fields("birth", "昭和60年1月1日", "expiry", "平成30年2月1日"), []string{"birth", "expiry"}, StatusValid},
+		{"expiry clamped to end of month", "license_expiry_birthday", fields("birth", "1990年1月31日", "expiry", "2024年2月29日"), []string{"birth", "expiry"}, StatusValid},
+		{"expiry in January after a December birthday", "license_expiry_birthday", fields("birth", "1990年12月15日", "expiry", "2027年1月15日"), []string{"birth", "expiry"}, StatusValid},
+		{"expiry before the month after a December birthday", "license_expiry_birthday", fields("birth", "1990年12月15日", "expiry", "2026年12月15日"), []string{"birth", "expiry"}, StatusInvalid},
+		{"expiry on birthday", "license_expiry_birthday", fields("birth", "昭和60年1月1日", "expiry", "平成30年1月1日"), []string{"birth", "expiry"}, StatusInvalid},
+
+		{"same address in other notation", "address_match", fields("front", "東京都千代田区霞が関２丁目１番１号", "back", "東京都千代田区霞が関2-1-1"), []string{"front", "back"}, StatusValid},
+		{"same address with kanji numerals", "address_match", fields("front", "東京都千代田区霞が関2丁目1番1号", "back", "東京都千代田区霞が関二丁目1-1"), []string{"front", "back"}, StatusValid},
+		{"different address", "address_match", fields("front", "東京都千代田区霞が関2-1-1", "back", "大阪府大阪市北区1-1"), []string{"front", "back"}, StatusInvalid},
+		{"no back address", "address_match", fields("front", "東京都千代田区霞が関2-1-1"), []string{"front", "back"}, StatusSkipped},
+
+		{"license issued in the prefecture", "license_prefecture", fields("number", "第301512345650号", "address", "東京都千代田区霞が関2-1-1"), []string{"number", "address"}, StatusValid},
+		{"license issued in another prefecture", "license_prefecture", fields("number", "301512345650", "address", "大阪府大阪市北区1-1"), []string{"number", "address"}, StatusInvalid},
+		{"address without prefecture", "license_prefecture", fields("number", "301512345650", "address", "千代田区霞が関2-1-1"), []string{"number", "address"}, StatusSkipped},
+		{"mrz with valid check digits", "mrz", fields("l1", mrzLine1, "l2", mrzLine2), []string{"l1", "l2"}, StatusValid},
+		{"mrz with a misread digit", "mrz", fields("l1", mrzLine1, "l2", strings.Replace(mrzLine2, "900101", "900107", 1)), []string{"l1", "l2"}, StatusInvalid},
+		{"mrz matching the visual zone", "mrz_match", fields("l1", mrzLine1, "l2", mrzLine2, "name", "Taro Yamada", "birth", "1990年1月1日", "sex", "男", "expiry", "2030-01-01", "number", "XY1234567"),
+			[]string{"l1", "l2", "name", "birth", "sex", "expiry", "number"}, StatusValid},
+		{"mrz not matching the visual zone", "mrz_match", fields("l1", mrzLine1, "l2", mrzLine2, "name", "YAMADA HANAKO", "birth", "1990年1月1日"),
+			[]string{"l1", "l2", "name", "birth", "sex", "expiry", "number"}, StatusInvalid},
+		{"mrz and a name in kanji", "mrz_match", fields("l1", mrzLine1, "l2", mrzLine2, "name", "山田太郎"), []string{"l1", "l2", "name"}, StatusSkipped},
+		{"invalid license number", "license_prefecture", fields("number", "301512345640", "address", "東京都千代田区霞が関2-1-1"), []string{"number", "address"}, StatusSkipped},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rule, ok := LookupRule(tc.rule)
+			if !ok {
+				t.Fatalf("rule %s is not registered", tc.rule)
+			}
+			got := rule(context.Background(), DocumentContext{Fields: tc.fields, Operands: tc.operands})
+			if got.Status != tc.expected {
+				t.Errorf("expected %s, but got %s (%s)", tc.expected, got.Status, got.Reason)
+			}
+		})
+	}
+}
+
+func TestParseDriverLicenseNumber(t *testing.T) {
+	t.Run("should decode the parts of the number", func(t *testing.T) {
+		got, err := ParseDriverLicenseNumber("第６２０５０００００１５３号")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		expected := DriverLicenseNumber{
+			Number:          "620500000153",
+			CommissionCode:  "62",
+			Commission:      "大阪府公安委員会",
+			Prefecture:      "大阪府",
+			AcquisitionYear: 2005,
+			Serial:          "000001",
+			CheckDigit:      5,
+			ReissueCount:    3,
+		}
+		if got != expected {
+			t.Errorf("expected %+v, but got %+v", expected, got)
+		}
+	})
+
+	t.Run("should expand the acquisition year relative to the given time", func(t *testing.T) {
+		got, err := ParseDriverLicenseNumberAt("620500000153", time.Date(2004, time.June, 1, 0, 0, 0, 0, time.UTC))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got.AcquisitionYear != 1905 {
+			t.Errorf("expected 1905, but got %d", got.AcquisitionYear)
+		}
+	})
+
+	t.Run("should report the part that is wrong", func(t *testing.T) {
+		reasons := map[string]string{
+			"30151234565":  "has 11 digits instead of 12",
+			"3015123456X0": "contains a non-digit character 'X'",
+			"991512345650": "unknown public safety commission code 99",
+			"301512345640": "check digit mismatch",
+		}
+		for number, reason := range reasons {
+			_, err := ParseDriverLicenseNumber(number)
+			if err == nil || err.Error() != reason {
+				t.Errorf("expected error %q for %s, but got %v", reason, number, err)
+			}
+		}
+	})
+
+	t.Run("should expand the year of first acquisition", func(t *testing.T) {
+		if got := expandYear(98, 2024); got != 1998 {
+			t.Errorf("expected 1998, but got %d", got)
+		}
+		if got := expandYear(24, 2024); got != 2024 {
+			t.Errorf("expected 2024, but got %d", got)
+		}
+	})
+}
+
+const (
+	mrzLine1 = "P<JPNYAMADA<<TARO<<<<<<<<<<<<<<<<<<<<<<<<<<<"
+	mrzLine2 = "XY12345671JPN9001011M3001019<<<<<<<<<<<<<<04"
+)
+
+func TestParseMRZ(t *testing.T) {
+	t.Run("should decode the ICAO specimen", func(t *testing.T) {
+		got, err := ParseMRZ("P<UTOERIKSSON<<ANNA<MARIA<<<<<<<<<<<<<<<<<<<", "L898902C36UTO7408122F1204159ZE184226B<<<<<10")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		expected := MRZ{
+			DocumentCode:   "P",
+			IssuingState:   "UTO",
+			Surname:        "ERIKSSON",
+			GivenNames:     "ANNA MARIA",
+			PassportNumber: "L898902C3",
+			Nationality:    "UTO",
+			BirthDate:      time.Date(1974, time.August, 12, 0, 0, 0, 0, time.UTC),
+			Sex:            "F",
+			ExpiryDate:     time.Date(2012, time.April, 15, 0, 0, 0, 0, time.UTC),
+			PersonalNumber: "ZE184226B",
+		}
+		if got != expected {
+			t.Errorf("expected %+v, but got %+v", expected, got)
+		}
+	})
+
+	t.Run("should expand the years relative to the given time", func(t *testing.T) {
+		got, err := ParseMRZAt(mrzLine1, mrzLine2, time.Date(1985, time.January, 1, 0, 0, 0, 0, time.UTC))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got.BirthDate.Year() != 1890 || got.ExpiryDate.Year() != 1930 {
+			t.Errorf("expected birth in 1890 and expiry in 1930, but got %v and %v", got.BirthDate, got.ExpiryDate)
+		}
+	})
+
+	t.Run("should accept lines read with spaces and missing fillers", func(t *testing.T) {
+		got, err := ParseMRZ("P<JPN YAMADA<<TARO", "XY12345671JPN 9001011M3001019 <<<<<<<<<<<<<<04")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got.Surname != "YAMADA" || got.GivenNames != "TARO" || got.PassportNumber != "XY1234567" {
+			t.Errorf("unexpected MRZ: %+v", got)
+		}
+	})
+
+	t.Run("should report the part that is wrong", func(t *testing.T) {
+		reasons := map[string]string{
+			"XY12345681JPN9001011M3001019<<<<<<<<<<<<<<04": "passport number check digit mismatch",
+			"XY12345671JPN9001021M3001019<<<<<<<<<<<<<<04": "birth date check digit mismatch",
+			"XY12345671JPN9001011M3001018<<<<<<<<<<<<<<04": "expiry date check digit mismatch",
+			"XY12345671JPN9001011M3001019<<<<<<<<<<<<<<05": "composite check digit mismatch",
+			"XY12345671JPN9001011M3001019<<<<<<<<<<<<<<0":  "line 2 has 43 characters instead of 44",
+		}
+		for line2, reason := range reasons {
+			_, err := ParseMRZ(mrzLine1, line2)
+			if err == nil || err.Error() != reason {
+				t.Errorf("expected error %q for %s, but got %v", reason, line2, err)
+			}
+		}
+	})
+}
+
+func TestResidenceCardValidators(t *testing.T) {
+	testCases := []struct {
+		validator string
+		value     string
+		expected  Status
+		reason    string
+	}{
+		{"residence_card_number", "AB12345678CD", StatusValid, ""},
+		{"residence_card_number", "ａｂ１２３４５６７８ｃｄ", StatusValid, ""},
+		{"residence_card_number", "AB 1234 5678 CD", StatusValid, ""},
+		{"residence_card_number", "1234567", StatusInvalid, "has 7 characters instead of 12"},
+		{"residence_card_number", "AB1234567OCD", StatusInvalid, "is not 2 letters, 8 digits and 2 letters"},
+		{"special_permanent_resident_number", "AB12345678CD", StatusValid, ""},
+		{"special_permanent_resident_number", "1234567", StatusInvalid, "has 7 characters instead of 12"},
+		{"period_of_stay", "5年", StatusValid, ""},
+		{"period_of_stay", "4年3月 (2028年01月01日)", StatusValid, ""},
+		{"period_of_stay", "無期限", StatusValid, ""},
+		{"period_of_stay", "7年", StatusInvalid, `"7年" is not a period of stay`},
+		{"work_restrictions", "就労不可", StatusValid, ""},
+		{"work_restrictions", "指定書記載機関での在留資格に基づく就労活動のみ可", StatusValid, ""},
+		{"work_restrictions", "就労可", StatusInvalid, `"就労可" is not a work restriction`},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.validator+" "+tc.value, func(t *testing.T) {
+			validate, ok := Lookup(tc.validator)
+			if !ok {
+				t.Fatalf("validator %s is not registered", tc.validator)
+			}
+			got := validate(context.Background(), FieldContext{Value: tc.value})
+			if got.Status != tc.expected || got.Reason != tc.reason {
+				t.Errorf("expected %s (%s), but got %s (%s)", tc.expected, tc.reason, got.Status, got.Reason)
+			}
+		})
+	}
+}
+
+func TestInsurerNumber(t *testing.T) {
+	testCases := []struct {
+		name     string
+		number   string
+		expected bool
+	}{
+		{"japan health insurance association", "01130012", true},
+		{"with separators", "0113-0012", true},
+		{"full-width digits", "０１１３００１２", true},
+		{"national health insurance", "138057", true},
+		{"misread digit", "01180012", false},
+		{"unknown prefecture", "01480011", false},
+		{"invalid length", "0113001", false},
+		{"invalid char", "0113001A", false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := ValidateInsurerNumber(tc.number); got != tc.expected {
+				t.Errorf("expected %v, but got %v for number %s", tc.expected, got, tc.number)
+			}
+		})
+	}
+
+	branch, _ := Lookup("branch_number")
+	for value, expected := range map[string]Status{"00": StatusValid, "０１": StatusValid, "1": StatusInvalid, "0A": StatusInvalid} {
+		if got := branch(context.Background(), FieldContext{Value: value}); got.Status != expected {
+			t.Errorf("expected %s for branch number %s, but got %s (%s)", expected, value, got.Status, got.Reason)
+		}
+	}
+}
+
+func TestCorporateNumbers(t *testing.T) {
+	testCases := []struct {
+		name     string
+		validate func(string) bool
+		number   string
+		expected bool
+	}{
+		{"corporate number", ValidateCorporateNumber, "7000012050002", true},
+		{"corporate number with separators", ValidateCorporateNumber, "4-0100-01-123456", true},
+		{"corporate number with misread digit", ValidateCorporateNumber, "7000012050003", false},
+		{"corporate number with invalid length", ValidateCorporateNumber, "700001205000", false},
+		{"invoice registration number", ValidateInvoiceRegistrationNumber, "T7000012050002", true},
+		{"invoice registration number with full-width characters", ValidateInvoiceRegistrationNumber, "Ｔ７００００１２０５０００２", true},
+		{"invoice registration number without T", ValidateInvoiceRegistrationNumber, "7000012050002", false},
+		{"invoice registration number with misread digit", ValidateInvoiceRegistrationNumber, "T7000012050003", false},
+		{"basic pension number", ValidateBasicPensionNumber, "1234-567890", true},
+		{"basic pension number without separator", ValidateBasicPensionNumber, "1234567890", true},
+		{"basic pension number with invalid length", ValidateBasicPensionNumber, "1234-56789", false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.validate(tc.number); got != tc.expected {
+				t.Errorf("expected %v, but got %v for number %s", tc.expected, got, tc.number)
+			}
+		})
+	}
+
+	t.Run("should derive the corporate number from the company registration number", func(t *testing.T) {
+		got, err := CorporateNumber("0100-01-123456")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got != "4010001123456" {
+			t.Errorf("expected 4010001123456, but got %s", got)
+		}
+		if _, err := CorporateNumber("0100-01-12345"); err == nil {
+			t.Error("expected an error, but got nil")
+		}
+	})
+
+	t.Run("should report the part that is wrong", func(t *testing.T) {
+		validate, _ := Lookup("invoice_registration_number")
+		reasons := map[string]string{
+			"7000012050002":  "does not start with T",
+			"T700001205000":  "has 12 digits instead of 13",
+			"T7000012050003": "check digit mismatch",
+		}
+		for number, reason := range reasons {
+			if got := validate(context.Background(), FieldContext{Value: number}); got.Reason != reason {
+				t.Errorf("expected reason %q for %s, but got %q", reason, number, got.Reason)
+			}
+		}
+	})
+}
+
+func TestAddressValidator(t *testing.T) {
+	validate, ok := Lookup("address")
+	if !ok {
+		t.Fatal("expected the address validator to be registered")
+	}
+	testCases := map[string]Status{
+		"東京都千代田区霞が関2-1-1": StatusValid,
+		"千代田区霞が関2-1-1":    StatusValid,
+		"東京府千代田区霞が関2-1-1": StatusInvalid,
+		" ":               StatusSkipped,
+	}
+	for value, expected := range testCases {
+		if got := validate(context.Background(), FieldContext{Value: value}); got.Status != expected {
+			t.Errorf("expected %s for %q, but got %s (%s)", expected, value, got.Status, got.Reason)
+		}
+	}
+}
+
+func TestParseJapaneseDate(t *testing.T) {
+	testCases := []struct {
+		date     string
+		expected string
+	}{
+		{"平成30年2月1日", "2018-02-01"},
+		{"令和元年5月1日", "2019-05-01"},
+		{"H30.2.1", "2018-02-01"},
+		{"R1/5/1", "2019-05-01"},
+		{"r01-05-01", "2019-05-01"},
+		{"S60.1.1", "1985-01-01"},
+		{"平30.2.1", "2018-02-01"},
+		{"Ｈ３０．２．１", "2018-02-01"},
+		{"令和３年９月２２日", "2021-09-22"},
+		{"平成三十年二月一日", "2018-02-01"},
+		{"昭和六十年十二月二十五日", "1985-12-25"},
+		{"二〇二三年一月十五日", "2023-01-15"},
+		{"2018年2月1日", "2018-02-01"},
+		{"2018-02-01", "2018-02-01"},
+		{"2018/02/01", "2018-02-01"},
+		{"2018/2/1", "2018-02-01"},
+		{"2018.2.1", "2018-02-01"},
+		{"平成30年 2月 1日", "2018-02-01"},
+		{"２０１８年　２月　１日", "2018-02-01"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.date, func(t *testing.T) {
+			got, err := ParseJapaneseDate(tc.date)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got.Format("2006-01-02") != tc.expected {
+				t.Errorf("expected %s, but got %s", tc.expected, got.Format("2006-01-02"))
+			}
+		})
+	}
+
+	for _, date := range []string{"H30.2.30", "X30.2.1", "2023/13/15", "2023.1", "平成"} {
+		if _, err := ParseJapaneseDate(date); err == nil {
+			t.Errorf("expected error for %s, but got nil", date)
+		}
+	}
+}
+
+func TestToWareki(t *testing.T) {
+	testCases := []struct {
+		date     time.Time
+		expected string
+	}{
+		{time.Date(2021, time.September, 22, 0, 0, 0, 0, time.UTC), "令和3年9月22日"},
+		{time.Date(2019, time.May, 1, 0, 0, 0, 0, time.UTC), "令和元年5月1日"},
+		{time.Date(2019, time.April, 30, 0, 0, 0, 0, time.UTC), "平成31年4月30日"},
+		{time.Date(1989, time.January, 7, 0, 0, 0, 0, time.UTC), "昭和64年1月7日"},
+		{time.Date(1989, time.January, 8, 0, 0, 0, 0, time.UTC), "平成元年1月8日"},
+		{time.Date(1926, time.December, 25, 0, 0, 0, 0, time.UTC), "昭和元年12月25日"},
+		{time.Date(1926, time.December, 24, 0, 0, 0, 0, time.UTC), "大正15年12月24日"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.expected, func(t *testing.T) {
+			got, err := ToWareki(tc.date)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tc.expected {
+				t.Errorf("expected %s, but got %s", tc.expected, got)
+			}
+			back, err := ParseJapaneseDate(got)
+			if err != nil || !back.Equal(tc.date) {
+				t.Errorf("expected %s to parse back to %s, but got %s (%v)", got, tc.date.Format("2006-01-02"), back.Format("2006-01-02"), err)
+			}
+		})
+	}
+
+	t.Run("should fail before the first era", func(t *testing.T) {
+		if _, err := ToWareki(time.Date(1868, time.January, 1, 0, 0, 0, 0, time.UTC)); err == nil {
+			t.Error("expected an error, but got nil")
+		}
+	})
+}
+
+func TestRegisterEra(t *testing.T) {
+	original := Eras()
+	t.Cleanup(func() {
+		erasMu.Lock()
+		eras = original
+		compileEras()
+		erasMu.Unlock()
+	})
+
+	t.Run("should reject an era that does not start after the last one", func(t *testing.T) {
+		err := RegisterEra(Era{Name: "試験", Abbreviation: "X", Start: time.Date(2019, time.May, 1, 0, 0, 0, 0, time.UTC)})
+		if !errors.Is(err, ErrInvalidEra) {
+			t.Errorf("expected ErrInvalidEra, but got %v", err)
+		}
+	})
+
+	t.Run("should reject a duplicate abbreviation", func(t *testing.T) {
+		err := RegisterEra(Era{Name: "試験", Abbreviation: "r", Start: time.Date(2100, time.January, 1, 0, 0, 0, 0, time.UTC)})
+		if !errors.Is(err, ErrInvalidEra) {
+			t.Errorf("expected ErrInvalidEra, but got %v", err)
+		}
+	})
+
+	t.Run("should read and render dates of a new era", func(t *testing.T) {
+		if err := RegisterEra(Era{Name: "試験", Abbreviation: "X", Start: time.Date(2100, time.April, 1, 0, 0, 0, 0, time.UTC)}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for date, expected := range map[string]string{"試験元年4月1日": "2100-04-01", "X2.1.1": "2101-01-01", "令和82年3月31日": "2100-03-31"} {
+			got, err := ParseJapaneseDate(date)
+			if err != nil {
+				t.Errorf("unexpected error for %s: %v", date, err)
+			} else if got.Format("2006-01-02") != expected {
+				t.Errorf("expected %s, but got %s", expected, got.Format("2006-01-02"))
+			}
+		}
+		if ValidateDate("令和82年4月1日") {
+			t.Error("expected 令和82年4月1日 to be invalid after the new era started")
+		}
+		if got, _ := ToWareki(time.Date(2100, time.April, 1, 0, 0, 0, 0, time.UTC)); got != "試験元年4月1日" {
+			t.Errorf("expected 試験元年4月1日, but got %s", got)
+		}
+	})
+}
+
+func TestNotExpired(t *testing.T) {
+	validate, ok := Lookup("not_expired")
+	if !ok {
+		t.Fatal("expected validator not_expired to be registered")
+	}
+	now := time.Date(2024, time.March, 1, 23, 59, 0, 0, time.UTC)
+	testCases := []struct {
+		value    string
+		expected Status
+	}{
+		{"令和6年2月29日", StatusInvalid},
+		{"令和6年3月1日", StatusValid},
+		{"2030-01-01", StatusValid},
+		{"不明", StatusSkipped},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.value, func(t *testing.T) {
+			got := validate(context.Background(), FieldContext{Name: "expiry_date", Value: tc.value, Now: now})
+			if got.Status != tc.expected {
+				t.Errorf("expected %s, but got %s (%s)", tc.expected, got.Status, got.Reason)
+			}
+		})
+	}
+}